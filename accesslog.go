@@ -0,0 +1,64 @@
+package metacontroller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog creates an option that logs one line per hook request with the
+// HookServer's logger once the request completes, recording the method, path,
+// parent, status code, and duration. Health, readiness, and metrics endpoints
+// are not logged.
+func AccessLog() Option {
+	return func(hs *HookServer) {
+		hs.accessLog = true
+	}
+}
+
+// AccessLogger is like AccessLog but writes the access log to logger.
+func AccessLogger(logger *slog.Logger) Option {
+	return func(hs *HookServer) {
+		hs.accessLog = true
+		hs.accessLogger = logger
+	}
+}
+
+// accessLogKey is the context key for the access log entry of a hook request.
+type accessLogKey struct{}
+
+// accessLogEntry collects fields of an access log line that are only known to
+// the hook handler.
+type accessLogEntry struct {
+	parent string
+}
+
+// setAccessLogParent records the parent of a hook request in its access log
+// entry, if access logging is enabled.
+func setAccessLogParent(ctx context.Context, namespace, name string) {
+	entry, ok := ctx.Value(accessLogKey{}).(*accessLogEntry)
+	if !ok {
+		return
+	}
+	entry.parent = name
+	if namespace != "" {
+		entry.parent = namespace + "/" + name
+	}
+}
+
+// accessLogMiddleware logs each request to a hook route after it completes.
+func accessLogMiddleware(logger *slog.Logger, rt hookRoute, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+		logger.InfoContext(r.Context(), "Handled "+rt.hookType+" hook request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"parent", entry.parent,
+			"status", rec.Status(),
+			"duration", time.Since(start))
+	})
+}
@@ -0,0 +1,154 @@
+package metacontroller
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Auth creates an option that requires every hook request to carry a bearer
+// token in its Authorization header. The token is passed to verify before the
+// hook handler runs; requests with a missing token or for which verify returns
+// an error are rejected with 401 Unauthorized.
+func Auth(verify func(ctx context.Context, token string) error) Option {
+	return func(hs *HookServer) {
+		hs.verifyToken = verify
+	}
+}
+
+// StaticToken creates an option that requires every hook request to carry the
+// given bearer token. Tokens are compared in constant time.
+func StaticToken(token string) Option {
+	return Auth(func(_ context.Context, got string) error {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return errors.New("invalid bearer token")
+		}
+
+		return nil
+	})
+}
+
+// HMACVerify creates an option that requires every hook request to carry an
+// HMAC-SHA256 of its body, keyed with secret, in the given header. The signature
+// is hex encoded and may be prefixed with "sha256=". The body is read in full
+// (subject to MaxRequestBytes) and compared in constant time before it is
+// decoded; requests with a missing or mismatched signature are rejected with
+// 401 Unauthorized. It can be combined with Auth or StaticToken.
+func HMACVerify(secret []byte, header string) Option {
+	return func(hs *HookServer) {
+		hs.hmacSecret = secret
+		hs.hmacHeader = header
+	}
+}
+
+// hmacMiddleware rejects hook requests whose body does not match the HMAC
+// signature in header. The verified body replaces r.Body for the next handler.
+func hmacMiddleware(secret []byte, header string, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig := r.Header.Get(header)
+		if sig == "" {
+			writeError(r.Context(), w, http.StatusUnauthorized, fmt.Errorf("missing %s signature header", header), logger)
+
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(r.Context(), w, http.StatusRequestEntityTooLarge, err, logger)
+
+				return
+			}
+			writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("error reading request: %w", err), logger)
+
+			return
+		}
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			writeError(r.Context(), w, http.StatusUnauthorized, fmt.Errorf("malformed %s signature: %w", header, err), logger)
+
+			return
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			writeError(r.Context(), w, http.StatusUnauthorized, errors.New("request signature mismatch"), logger)
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireHeader creates an option that requires every hook request to carry
+// the header name with the given value, e.g. a User-Agent or custom header set
+// by the Metacontroller deployment. Values are compared in constant time, and
+// requests with a missing or different value are rejected with 403 Forbidden
+// before they are read. Repeating the option requires every header given.
+func RequireHeader(name, value string) Option {
+	return func(hs *HookServer) {
+		hs.requiredHeaders = append(hs.requiredHeaders, requiredHeader{name: name, value: value})
+	}
+}
+
+// requiredHeader is a header set with RequireHeader.
+type requiredHeader struct {
+	name  string
+	value string
+}
+
+// requireHeadersMiddleware rejects hook requests that do not carry every
+// required header value.
+func requireHeadersMiddleware(headers []requiredHeader, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range headers {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(h.name)), []byte(h.value)) != 1 {
+				writeError(r.Context(), w, http.StatusForbidden, fmt.Errorf("missing or invalid %s header", h.name), logger)
+
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware rejects hook requests that do not carry a valid bearer token.
+func authMiddleware(verify func(context.Context, string) error, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(r.Context(), w, http.StatusUnauthorized, errors.New("missing bearer token"), logger)
+
+			return
+		}
+		if err := verify(r.Context(), token); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(r.Context(), w, http.StatusUnauthorized, fmt.Errorf("token verification failed: %w", err), logger)
+
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the bearer token from the request's Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
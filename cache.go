@@ -0,0 +1,168 @@
+package metacontroller
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SyncCache creates an option that caches sync hook responses for ttl, keyed
+// by a hash of the hook path and the full request (controller, parent, observed
+// children, and related objects), and serves repeated requests from the cache
+// without calling the Syncer. At most size responses are kept; the least
+// recently used is evicted first. Only 200 OK responses are cached, and
+// responses served from the cache carry an X-Metacontroller-Cache: hit header.
+//
+// A cached response skips the Syncer entirely, including side effects such as
+// ParentPatcher, so use it only with Syncers that are pure functions of their
+// request. Middleware, authentication, and metrics still run on every request.
+func SyncCache(ttl time.Duration, size int) Option {
+	return func(hs *HookServer) {
+		if ttl <= 0 || size <= 0 {
+			hs.syncCache = nil
+
+			return
+		}
+		hs.syncCache = newResponseCache(ttl, size)
+	}
+}
+
+// responseCache is an LRU cache of encoded hook responses with a TTL.
+type responseCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+}
+
+// cachedResponse is a responseCache entry.
+type cachedResponse struct {
+	key     [sha256.Size]byte
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// newResponseCache returns an empty responseCache.
+func newResponseCache(ttl time.Duration, size int) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the unexpired response cached under key.
+func (c *responseCache) get(key [sha256.Size]byte) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	return entry, true
+}
+
+// put caches a response under key, evicting the least recently used entry if
+// the cache is full.
+func (c *responseCache) put(key [sha256.Size]byte, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cachedResponse{key: key, header: header, body: body, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheMiddleware serves sync requests from cache, and caches the successful
+// responses of next. The key is computed from the request decoded by
+// decodeMiddleware and its Accept header, which selects the response encoding;
+// requests that failed to decode are passed through.
+func (hs *HookServer) cacheMiddleware(cache *responseCache, rt hookRoute, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, ok := r.Context().Value(hookRequestKey{}).(*hookRequest)
+		if !ok || req.err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		data, err := json.Marshal(req.raw)
+		if err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		key := sha256.Sum256(append([]byte(rt.path+"\n"+r.Header.Get("Accept")+"\n"), data...))
+
+		if entry, ok := cache.get(key); ok {
+			for k, v := range entry.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("X-Metacontroller-Cache", "hit")
+			hs.logger.DebugContext(r.Context(), "Serving cached "+rt.hookType+" response", "path", rt.path)
+			_, _ = w.Write(entry.body)
+
+			return
+		}
+
+		rec := &bufferingRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == http.StatusOK {
+			cache.put(key, w.Header().Clone(), bytes.Clone(rec.body.Bytes()))
+		}
+	})
+}
+
+// bufferingRecorder records the status code and a copy of the body written
+// through it.
+type bufferingRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code before delegating to the wrapped writer.
+func (br *bufferingRecorder) WriteHeader(code int) {
+	if br.status == 0 {
+		br.status = code
+	}
+	br.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 OK status and a copy of b before delegating to
+// the wrapped writer.
+func (br *bufferingRecorder) Write(b []byte) (int, error) {
+	if br.status == 0 {
+		br.status = http.StatusOK
+	}
+	br.body.Write(b)
+
+	return br.ResponseWriter.Write(b)
+}
@@ -0,0 +1,251 @@
+package metacontroller
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	goruntime "runtime"
+	"slices"
+	"strconv"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// skippedChildrenHeader is the response header that reports how many observed
+// children were skipped because they could not be decoded.
+const skippedChildrenHeader = "X-Metacontroller-Skipped-Children"
+
+// parallelDecodeThreshold is the number of observed children above which
+// children are decoded concurrently.
+const parallelDecodeThreshold = 64
+
+// rawChild is a single observed child awaiting decoding.
+type rawChild struct {
+	key  string
+	name string
+	data json.RawMessage
+}
+
+// decodedChild is the result of decoding a rawChild.
+type decodedChild struct {
+	gvk   schema.GroupVersionKind
+	child client.Object
+	err   error
+}
+
+// decodeChildren decodes the observed children of a composite request, grouped
+// by GroupVersionKind. Each group is sorted by namespace, then by name, so the
+// order Syncers see is deterministic across requests, and large child sets are
+// decoded concurrently on a bounded pool of workers. Children that cannot be
+// decoded are logged and skipped; the returned errors describe each skipped child.
+func decodeChildren(ctx context.Context, decoder runtime.Decoder, keys childKeys, rawChildren map[string]map[string]json.RawMessage, logger *slog.Logger, hook string) (map[schema.GroupVersionKind][]client.Object, []error) {
+	var raws []rawChild
+	for _, key := range slices.Sorted(maps.Keys(rawChildren)) {
+		for _, name := range slices.Sorted(maps.Keys(rawChildren[key])) {
+			raws = append(raws, rawChild{key: key, name: name, data: rawChildren[key][name]})
+		}
+	}
+
+	results := make([]decodedChild, len(raws))
+	decode := func(i int) {
+		results[i] = decodeChild(decoder, keys, raws[i])
+	}
+	if len(raws) <= parallelDecodeThreshold {
+		for i := range raws {
+			decode(i)
+		}
+	} else {
+		parallelize(len(raws), goruntime.GOMAXPROCS(0), decode)
+	}
+
+	var errs []error
+	observedChildren := make(map[schema.GroupVersionKind][]client.Object)
+	for i, res := range results {
+		if res.err != nil {
+			logger.ErrorContext(ctx,
+				hook+": error decoding child",
+				"error", res.err.Error(),
+				"child", string(raws[i].data))
+			errs = append(errs, fmt.Errorf("child %s %s: %w", raws[i].key, raws[i].name, res.err))
+
+			continue
+		}
+		observedChildren[res.gvk] = append(observedChildren[res.gvk], res.child)
+	}
+	for _, children := range observedChildren {
+		slices.SortStableFunc(children, compareObjects)
+	}
+
+	return observedChildren, errs
+}
+
+// compareObjects orders objects by namespace, then by name.
+func compareObjects(a, b client.Object) int {
+	if c := cmp.Compare(a.GetNamespace(), b.GetNamespace()); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.GetName(), b.GetName())
+}
+
+// decodeChild decodes a single observed child. The kind of the key it is listed
+// under supplies the apiVersion and kind the child lacks, which are stamped on
+// the decoded object; a child of a different kind is an error.
+func decodeChild(decoder runtime.Decoder, keys childKeys, raw rawChild) decodedChild {
+	var defaults *schema.GroupVersionKind
+	keyGVK, keyed := keys.kind(raw.key)
+	if keyed {
+		defaults = &keyGVK
+	}
+	if typed, ok := decoder.(typedChildDecoder); ok && keyed && !isProtobuf(raw.data) {
+		if child, ok, err := typed.decodeKind(keyGVK, raw.data); ok {
+			if err == nil {
+				child.GetObjectKind().SetGroupVersionKind(keyGVK)
+			}
+
+			return decodedChild{gvk: keyGVK, child: child, err: err}
+		}
+	}
+
+	obj, gvk, err := decoder.Decode(raw.data, defaults, nil)
+	if err != nil {
+		return decodedChild{err: err}
+	}
+
+	child, ok := obj.(client.Object)
+	if !ok {
+		return decodedChild{err: fmt.Errorf("type assertion failure: %T is not a client.Object", obj)}
+	}
+	if keyed && *gvk != keyGVK {
+		return decodedChild{err: fmt.Errorf("apiVersion %s, kind %s does not match the key", gvk.GroupVersion(), gvk.Kind)}
+	}
+	child.GetObjectKind().SetGroupVersionKind(*gvk)
+
+	return decodedChild{gvk: *gvk, child: child}
+}
+
+// ChildKeyFunc creates an option that sets how the kinds of observed children
+// and related objects are keyed in hook requests, for Metacontroller versions
+// whose keys differ from composition.KeyForGVK, the default. Keys are resolved
+// to the kinds registered in each hook's scheme and passed to TypedChildren;
+// other keys are parsed with composition.ParseKey and only used if fn formats
+// the result back into the same key. Children are still decoded by their own
+// apiVersion and kind, which must match their key; children without them take
+// them from their key. Sync responses list children without keys; fn also keys
+// the per-kind counts in sync logs.
+func ChildKeyFunc(fn func(schema.GroupVersionKind) string) Option {
+	return func(hs *HookServer) {
+		hs.childKey = fn
+	}
+}
+
+// childKeys resolves the keys of the children and related maps of a hook
+// request to kinds.
+type childKeys struct {
+	format func(schema.GroupVersionKind) string
+	kinds  map[string]schema.GroupVersionKind
+}
+
+// newChildKeys returns a childKeys that formats keys with format and resolves
+// them to the kinds registered in scheme and the kinds of typed. Keys that
+// format yields for more than one kind are not resolved.
+func newChildKeys(format func(schema.GroupVersionKind) string, scheme *runtime.Scheme, typed map[schema.GroupVersionKind]func() client.Object) childKeys {
+	kinds := make(map[string]schema.GroupVersionKind)
+	ambiguous := make(map[string]bool)
+	add := func(gvk schema.GroupVersionKind) {
+		key := format(gvk)
+		if existing, ok := kinds[key]; ok && existing != gvk {
+			ambiguous[key] = true
+		}
+		kinds[key] = gvk
+	}
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version != runtime.APIVersionInternal {
+			add(gvk)
+		}
+	}
+	for gvk := range typed {
+		add(gvk)
+	}
+	for key := range ambiguous {
+		delete(kinds, key)
+	}
+
+	return childKeys{format: format, kinds: kinds}
+}
+
+// key returns the key of gvk.
+func (k childKeys) key(gvk schema.GroupVersionKind) string {
+	return k.format(gvk)
+}
+
+// kind returns the kind listed under key. It reports false if key cannot be
+// resolved.
+func (k childKeys) kind(key string) (schema.GroupVersionKind, bool) {
+	if gvk, ok := k.kinds[key]; ok {
+		return gvk, true
+	}
+	gvk, err := composition.ParseKey(key)
+	if err != nil || k.format(gvk) != key {
+		return schema.GroupVersionKind{}, false
+	}
+
+	return gvk, true
+}
+
+// parallelize calls fn for every index in [0, n) using at most workers goroutines.
+func parallelize(n, workers int, fn func(i int)) {
+	workers = max(1, min(workers, n))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// handleChildErrors reports children skipped by decodeChildren. In strict mode
+// it writes a 400 Bad Request and returns false; otherwise it records the number
+// of skipped children in a response header and log field and returns true.
+// Children sent as protobuf that have no protobuf encoding always fail the
+// request with 415 Unsupported Media Type.
+func handleChildErrors(ctx context.Context, w http.ResponseWriter, errs []error, strict bool, logger *slog.Logger, hook string) bool {
+	if len(errs) == 0 {
+		return true
+	}
+	if err := errors.Join(errs...); errors.Is(err, errNoProtobuf) {
+		writeError(ctx, w, http.StatusUnsupportedMediaType, fmt.Errorf("%s: error decoding children: %w", hook, err), logger)
+
+		return false
+	}
+	if strict {
+		writeError(ctx, w, http.StatusBadRequest, fmt.Errorf("%s: error decoding children: %w", hook, errors.Join(errs...)), logger)
+
+		return false
+	}
+
+	w.Header().Set(skippedChildrenHeader, strconv.Itoa(len(errs)))
+	logger.WarnContext(ctx, hook+": proceeding with incomplete observed children", "skippedChildren", len(errs))
+
+	return true
+}
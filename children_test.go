@@ -0,0 +1,111 @@
+package metacontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// secretChildren returns a children map with a Secret in namespace ns for each
+// of names, keyed as in a hook request.
+func secretChildren(ns string, names ...string) map[string]map[string]json.RawMessage {
+	byName := make(map[string]json.RawMessage, len(names))
+	for _, name := range names {
+		byName[ns+"/"+name] = json.RawMessage(fmt.Sprintf(
+			`{"apiVersion":"v1","kind":"Secret","metadata":{"name":%q,"namespace":%q,"labels":{"app":"bench"}},"data":{"k":"dg=="}}`,
+			name, ns))
+	}
+
+	return map[string]map[string]json.RawMessage{"Secret.v1": byName}
+}
+
+func TestDecodeChildrenStableOrder(t *testing.T) {
+	scheme := testScheme(t)
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	keys := newChildKeys(composition.KeyForGVK, scheme, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Sizes on both sides of parallelDecodeThreshold.
+	for _, n := range []int{10, 2 * parallelDecodeThreshold} {
+		t.Run(fmt.Sprintf("children=%d", n), func(t *testing.T) {
+			var want []string
+			for _, ns := range []string{"a", "b"} {
+				for i := range n / 2 {
+					want = append(want, fmt.Sprintf("%s/secret-%03d", ns, i))
+				}
+			}
+
+			for range 5 {
+				// Key the children in shuffled order, so neither the map keys
+				// nor the order they are added in give away the sorted order.
+				byKey := make(map[string]json.RawMessage, len(want))
+				for pos, i := range rand.Perm(len(want)) {
+					ns, name, _ := strings.Cut(want[i], "/")
+					for _, data := range secretChildren(ns, name)["Secret.v1"] {
+						byKey[fmt.Sprintf("child-%03d", pos)] = data
+					}
+				}
+				raw := map[string]map[string]json.RawMessage{"Secret.v1": byKey}
+
+				children, errs := decodeChildren(context.Background(), decoder, keys, raw, logger, "SyncHook")
+				if len(errs) > 0 {
+					t.Fatal(errs)
+				}
+				var got []string
+				for _, child := range children[corev1.SchemeGroupVersion.WithKind("Secret")] {
+					got = append(got, child.GetNamespace()+"/"+child.GetName())
+				}
+				if !slices.Equal(got, want) {
+					t.Fatalf("children = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeChildren compares decoding 1,000 observed children one by one
+// with decodeChildren, which decodes sets above parallelDecodeThreshold
+// concurrently.
+func BenchmarkDecodeChildren(b *testing.B) {
+	scheme := testScheme(b)
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	keys := newChildKeys(composition.KeyForGVK, scheme, nil)
+	names := make([]string, 1000)
+	for i := range names {
+		names[i] = fmt.Sprintf("secret-%d", i)
+	}
+	raw := secretChildren("default", names...)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for key, byName := range raw {
+				for name, data := range byName {
+					if res := decodeChild(decoder, keys, rawChild{key: key, name: name, data: data}); res.err != nil {
+						b.Fatal(res.err)
+					}
+				}
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, errs := decodeChildren(context.Background(), decoder, keys, raw, logger, "SyncHook"); len(errs) > 0 {
+				b.Fatal(errs)
+			}
+		}
+	})
+}
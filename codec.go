@@ -0,0 +1,451 @@
+package metacontroller
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	kjson "sigs.k8s.io/json"
+)
+
+// AllowUnstructured creates an option that decodes objects whose kind is not
+// registered in the scheme as *unstructured.Unstructured instead of rejecting
+// them. This lets generic hooks (e.g. P = *unstructured.Unstructured or
+// P = client.Object) serve arbitrary CRDs without compiled-in types.
+func AllowUnstructured() Option {
+	return func(hs *HookServer) {
+		hs.allowUnstructured = true
+	}
+}
+
+// StrictChildDecoding creates an option that rejects sync and finalize requests
+// with 400 Bad Request when any observed child cannot be decoded. Without it,
+// such children are skipped and the number skipped is reported in the
+// X-Metacontroller-Skipped-Children response header and a "skippedChildren" log
+// field, since a hook acting on an incomplete view may wrongly recreate or prune
+// children.
+func StrictChildDecoding() Option {
+	return func(hs *HookServer) {
+		hs.strictChildren = true
+	}
+}
+
+// StrictDecoding creates an option that rejects parents containing fields
+// unknown to their Go type (or duplicate fields) with 400 Bad Request. The error
+// names the offending fields, which surfaces drift between a CRD and the Go
+// types early. Observed children are still decoded leniently, since the cluster
+// may legitimately return fields that older Go types do not model.
+func StrictDecoding() Option {
+	return func(hs *HookServer) {
+		hs.strictDecoding = true
+	}
+}
+
+// Codecs creates an option that replaces the codec factory used to decode
+// parents and children and to encode responses. (Default:
+// serializer.NewCodecFactory(scheme))
+//
+// StrictDecoding builds its own strict factory from the scheme and does not use
+// the factory set here.
+func Codecs(factory serializer.CodecFactory) Option {
+	return func(hs *HookServer) {
+		hs.codecs = factory
+	}
+}
+
+// WithEncoder sets the encoder a sync or finalize hook uses for the parent
+// status and each desired child. Its output is embedded in the JSON response
+// document, so it must produce JSON. Encoders that need the object's apiVersion
+// and kind must resolve them for objects with an empty TypeMeta.
+func WithEncoder(encoder runtime.Encoder) HookOption {
+	return func(cfg *hookConfig) {
+		cfg.encoder = encoder
+	}
+}
+
+// WithScheme sets the scheme a hook uses to decode its parent and children and
+// to encode its response, in place of the server's scheme. The hook's codec
+// factory is built from the scheme; the Codecs option does not apply to it.
+func WithScheme(scheme *runtime.Scheme) HookOption {
+	return func(cfg *hookConfig) {
+		cfg.scheme = scheme
+	}
+}
+
+// hookScheme returns the scheme used by a hook.
+func (hs *HookServer) hookScheme(cfg hookConfig) *runtime.Scheme {
+	if cfg.scheme != nil {
+		return cfg.scheme
+	}
+
+	return hs.scheme
+}
+
+// hookCodecs returns the codec factory used by a hook.
+func (hs *HookServer) hookCodecs(cfg hookConfig) serializer.CodecFactory {
+	if cfg.scheme != nil {
+		return serializer.NewCodecFactory(cfg.scheme)
+	}
+
+	return hs.codecs
+}
+
+// decoder returns the decoder used by a hook to decode children.
+// Objects are decoded into the version they were serialized with; no
+// conversion to an internal version takes place.
+func (hs *HookServer) decoder(cfg hookConfig) runtime.Decoder {
+	return hs.withUnstructuredFallback(hs.hookCodecs(cfg).UniversalDeserializer())
+}
+
+// parentDecoder returns the decoder used by a hook to decode parents, which is
+// strict when the StrictDecoding option is set.
+func (hs *HookServer) parentDecoder(cfg hookConfig) runtime.Decoder {
+	if !hs.strictDecoding {
+		return hs.decoder(cfg)
+	}
+	strict := serializer.NewCodecFactory(hs.hookScheme(cfg), serializer.EnableStrict)
+
+	return hs.withUnstructuredFallback(strict.UniversalDeserializer())
+}
+
+// withUnstructuredFallback wraps decoder with an unstructured fallback when the
+// AllowUnstructured option is set.
+func (hs *HookServer) withUnstructuredFallback(decoder runtime.Decoder) runtime.Decoder {
+	if hs.allowUnstructured {
+		return unstructuredFallbackDecoder{decoder: decoder}
+	}
+
+	return decoder
+}
+
+// childDecoder returns the decoder used by a hook to decode observed children.
+func (hs *HookServer) childDecoder(cfg hookConfig) runtime.Decoder {
+	if cfg.unstructuredChildren {
+		return unstructuredDecoder{}
+	}
+	if len(cfg.childTypes) > 0 {
+		return newTypedChildDecoder(cfg.childTypes, hs.decoder(cfg))
+	}
+
+	return hs.decoder(cfg)
+}
+
+// childKeys returns the resolver a hook uses for the keys of the children and
+// related maps.
+func (hs *HookServer) childKeys(cfg hookConfig) childKeys {
+	return newChildKeys(hs.childKey, hs.hookScheme(cfg), cfg.childTypes)
+}
+
+// TypedChildren decodes the hook's observed children of the given kinds
+// directly into the objects returned by their factories, e.g.
+// func() client.Object { return &appsv1.Deployment{} }. The kind is taken
+// from Metacontroller's children map, which skips the universal decoder's
+// scheme lookups and its extra pass over each child to read apiVersion and
+// kind; unmarshaling the child itself is unchanged. Each factory must return a
+// new, empty object of its kind's Go type: decoded children are handed to the
+// Syncer, which may retain them, so objects are not pooled. Children of other
+// kinds are decoded as usual. PreserveUnknownFields takes precedence over
+// TypedChildren.
+func TypedChildren(factories map[schema.GroupVersionKind]func() client.Object) HookOption {
+	return func(cfg *hookConfig) {
+		cfg.childTypes = factories
+	}
+}
+
+// typedChildDecoder decodes objects of known kinds into objects created by
+// their factories and falls back to the wrapped decoder for other kinds.
+type typedChildDecoder struct {
+	factories map[schema.GroupVersionKind]func() client.Object
+	decoder   runtime.Decoder
+}
+
+// newTypedChildDecoder returns a typedChildDecoder for factories that falls
+// back to decoder.
+func newTypedChildDecoder(factories map[schema.GroupVersionKind]func() client.Object, decoder runtime.Decoder) typedChildDecoder {
+	return typedChildDecoder{factories: factories, decoder: decoder}
+}
+
+// Decode implements runtime.Decoder.
+func (d typedChildDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	if into == nil {
+		if gvk, err := json.DefaultMetaFactory.Interpret(data); err == nil {
+			*gvk = gvkWithDefaults(*gvk, defaults)
+			if obj, ok, err := d.decodeKind(*gvk, data); ok {
+				return obj, gvk, err
+			}
+		}
+	}
+
+	return d.decoder.Decode(data, defaults, into)
+}
+
+// decodeKind decodes data into a new object of kind gvk. It reports false if
+// the kind has no factory.
+func (d typedChildDecoder) decodeKind(gvk schema.GroupVersionKind, data []byte) (client.Object, bool, error) {
+	factory, ok := d.factories[gvk]
+	if !ok {
+		return nil, false, nil
+	}
+
+	obj := factory()
+	if err := kjson.UnmarshalCaseSensitivePreserveInts(data, obj); err != nil {
+		return nil, true, err
+	}
+
+	return obj, true, nil
+}
+
+// unstructuredFallbackDecoder decodes with the wrapped decoder and falls back to
+// unstructured decoding for kinds that are not registered in the scheme.
+type unstructuredFallbackDecoder struct {
+	decoder runtime.Decoder
+}
+
+// Decode implements runtime.Decoder.
+func (d unstructuredFallbackDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	obj, gvk, err := d.decoder.Decode(data, defaults, into)
+	if err != nil && runtime.IsNotRegisteredError(err) {
+		return unstructuredDecoder{}.Decode(data, defaults, nil)
+	}
+
+	return obj, gvk, err
+}
+
+// unstructuredDecoder decodes objects as *unstructured.Unstructured. Unlike
+// unstructured.UnstructuredJSONScheme, it takes a missing apiVersion or kind
+// from the defaults, and it rejects objects sent as protobuf, which requires
+// registered types, with an error wrapping errNoProtobuf.
+type unstructuredDecoder struct{}
+
+// Decode implements runtime.Decoder.
+func (unstructuredDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	if isProtobuf(data) {
+		return nil, nil, fmt.Errorf("cannot decode as unstructured: %w", errNoProtobuf)
+	}
+	if defaults == nil || into != nil {
+		return unstructured.UnstructuredJSONScheme.Decode(data, defaults, into)
+	}
+
+	// A missing kind is reported after the object has been decoded into u.
+	u := &unstructured.Unstructured{}
+	if _, _, err := unstructured.UnstructuredJSONScheme.Decode(data, nil, u); err != nil && !runtime.IsMissingKind(err) {
+		return nil, nil, err
+	}
+	gvk := gvkWithDefaults(u.GroupVersionKind(), defaults)
+	if gvk.Kind == "" {
+		return nil, &gvk, runtime.NewMissingKindErr(string(data))
+	}
+	u.SetGroupVersionKind(gvk)
+
+	return u, &gvk, nil
+}
+
+// gvkWithDefaults fills the kind and group version that actual lacks from
+// defaults, as the scheme's JSON serializer does.
+func gvkWithDefaults(actual schema.GroupVersionKind, defaults *schema.GroupVersionKind) schema.GroupVersionKind {
+	if defaults == nil {
+		return actual
+	}
+	if actual.Kind == "" {
+		actual.Kind = defaults.Kind
+	}
+	if actual.Version == "" && actual.Group == "" {
+		actual.Group = defaults.Group
+		actual.Version = defaults.Version
+	}
+	if actual.Version == "" && actual.Group == defaults.Group {
+		actual.Version = defaults.Version
+	}
+
+	return actual
+}
+
+// decodeParent decodes a parent object and asserts it to P. When P is
+// *unstructured.Unstructured the parent is always decoded as unstructured,
+// regardless of whether its kind is registered in the scheme.
+func decodeParent[P client.Object](decoder runtime.Decoder, data []byte) (P, error) {
+	var parent P
+	if _, ok := any(parent).(*unstructured.Unstructured); ok {
+		decoder = unstructuredDecoder{}
+	}
+
+	obj, gvk, err := decoder.Decode(data, nil, nil)
+	if err != nil {
+		return parent, err
+	}
+
+	parent, ok := obj.(P)
+	if !ok {
+		return parent, fmt.Errorf("type assertion failure: parent %s is %T, not %T", gvk, obj, parent)
+	}
+
+	return parent, nil
+}
+
+// parentMatcher checks that a decoded parent is of the resource a hook was
+// registered for.
+type parentMatcher struct {
+	gvr    schema.GroupVersionResource
+	mapper meta.RESTMapper
+}
+
+// check returns an error if parent's kind does not belong to the matcher's
+// resource. The kind is resolved from the scheme and mapped to its resource with
+// the RESTMapper when set, or by guessing its plural otherwise. A matcher with
+// an empty resource accepts any parent.
+func (m parentMatcher) check(scheme *runtime.Scheme, parent client.Object) error {
+	if m.gvr.Empty() {
+		return nil
+	}
+
+	gvk, err := apiutil.GVKForObject(parent, scheme)
+	if err != nil {
+		return err
+	}
+	if gvk.Group != m.gvr.Group || gvk.Version != m.gvr.Version {
+		return fmt.Errorf("parent %s does not belong to %s", gvk, m.gvr)
+	}
+
+	if m.mapper != nil {
+		gvr, err := m.mapper.ResourceFor(m.gvr)
+		if err != nil {
+			return err
+		}
+		kind, err := m.mapper.KindFor(gvr)
+		if err != nil {
+			return err
+		}
+		if kind != gvk {
+			return fmt.Errorf("parent %s does not belong to %s, whose kind is %s", gvk, m.gvr, kind.Kind)
+		}
+
+		return nil
+	}
+
+	plural, singular := meta.UnsafeGuessKindToResource(gvk)
+	if m.gvr.Resource != plural.Resource && m.gvr.Resource != singular.Resource {
+		return fmt.Errorf("parent %s does not belong to %s", gvk, m.gvr)
+	}
+
+	return nil
+}
+
+// parentTypeChecker is implemented by hook handlers that can check their
+// parent type when registered.
+type parentTypeChecker interface {
+	checkParentType() error
+}
+
+// checkParentType returns an error if P is a concrete parent type that the hook
+// could never decode: one that is not registered in scheme or, when the
+// matcher has no RESTMapper, does not belong to the matcher's resource. Generic
+// parent types (interfaces and *unstructured.Unstructured) are not checked.
+func checkParentType[P client.Object](scheme *runtime.Scheme, matcher parentMatcher) error {
+	var zero P
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return nil
+	}
+	parent := reflect.New(typ.Elem()).Interface().(client.Object)
+	if _, ok := parent.(runtime.Unstructured); ok {
+		return nil
+	}
+
+	if _, err := apiutil.GVKForObject(parent, scheme); err != nil {
+		return fmt.Errorf("parent type %T is not registered in the scheme: %w", zero, err)
+	}
+	if matcher.mapper == nil {
+		return matcher.check(scheme, parent)
+	}
+
+	return nil
+}
+
+// encoder returns the encoder used by a hook to encode parent status and children.
+func (hs *HookServer) encoder(cfg hookConfig) runtime.Encoder {
+	if cfg.encoder != nil {
+		return cfg.encoder
+	}
+
+	scheme := hs.hookScheme(cfg)
+
+	return objectEncoder{
+		scheme: scheme,
+		codecs: hs.hookCodecs(cfg),
+		serializer: json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme, scheme,
+			json.SerializerOptions{}),
+	}
+}
+
+// protobufEncoder returns the encoder used by a hook to encode responses as
+// protobuf, or nil if the hook has a custom encoder, whose output is JSON.
+func (hs *HookServer) protobufEncoder(cfg hookConfig) runtime.Encoder {
+	if cfg.encoder != nil {
+		return nil
+	}
+
+	scheme := hs.hookScheme(cfg)
+
+	return objectEncoder{
+		scheme:     scheme,
+		codecs:     hs.hookCodecs(cfg),
+		serializer: protobuf.NewSerializer(scheme, scheme),
+		protobuf:   true,
+	}
+}
+
+// objectEncoder encodes objects as JSON, or as protobuf if protobuf is set, in
+// their own group version. Objects with an empty TypeMeta have their
+// GroupVersionKind resolved from the scheme, so the encoded output always
+// carries apiVersion and kind. Objects that have no protobuf encoding fail to
+// encode as protobuf with an error wrapping errNoProtobuf.
+type objectEncoder struct {
+	scheme     *runtime.Scheme
+	codecs     serializer.CodecFactory
+	serializer runtime.Serializer
+	protobuf   bool
+}
+
+// Encode implements runtime.Encoder.
+func (e objectEncoder) Encode(obj runtime.Object, w io.Writer) error {
+	gvk, err := apiutil.GVKForObject(obj, e.scheme)
+	if err != nil {
+		return fmt.Errorf("unable to determine apiVersion/kind of %T: %w", obj, err)
+	}
+
+	if u, ok := obj.(runtime.Unstructured); ok {
+		if gvk.Empty() {
+			return fmt.Errorf("unstructured object is missing apiVersion/kind")
+		}
+		if e.protobuf {
+			return fmt.Errorf("unstructured %s: %w", gvk, errNoProtobuf)
+		}
+
+		return unstructured.UnstructuredJSONScheme.Encode(u, w)
+	}
+
+	err = e.codecs.EncoderForVersion(e.serializer, gvk.GroupVersion()).Encode(obj, w)
+	if e.protobuf && protobuf.IsNotMarshalable(err) {
+		return fmt.Errorf("%T: %w", obj, errNoProtobuf)
+	}
+
+	return err
+}
+
+// Identifier implements runtime.Encoder.
+func (e objectEncoder) Identifier() runtime.Identifier {
+	if e.protobuf {
+		return runtime.Identifier("metacontroller-object-protobuf")
+	}
+
+	return runtime.Identifier("metacontroller-object-json")
+}
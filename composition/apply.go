@@ -0,0 +1,48 @@
+package composition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ApplyChildren applies the desired children of resp to the cluster with
+// Server-Side Apply, using fieldManager as the field owner and forcing
+// ownership of conflicting fields. Each child is copied and prepared for apply:
+// its apiVersion and kind are resolved from the client's scheme, and its
+// resourceVersion and managedFields are cleared. Every child is attempted, and
+// the errors of those that fail are joined.
+func ApplyChildren[P client.Object](ctx context.Context, c client.Client, resp *SyncResponse[P], fieldManager string) error {
+	var errs []error
+	for _, child := range resp.Children {
+		obj, err := applyConfiguration(c, child)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+			errs = append(errs, fmt.Errorf("error applying %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, objectKey(obj), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyConfiguration returns a copy of obj suitable for a Server-Side Apply patch.
+func applyConfiguration(c client.Client, obj client.Object) (client.Object, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, fmt.Errorf("error resolving kind of %s: %w", objectKey(obj), err)
+	}
+
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+
+	return obj, nil
+}
@@ -0,0 +1,70 @@
+package composition
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AttachmentConflict describes an attachment that was desired more than once.
+type AttachmentConflict struct {
+	// GroupVersionKind, Namespace, and Name identify the attachment.
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Objects are the conflicting desired objects, in the order given. The last
+	// one is the one merged.
+	Objects []client.Object
+}
+
+// Error implements error.
+func (c AttachmentConflict) Error() string {
+	name := c.Name
+	if c.Namespace != "" {
+		name = c.Namespace + "/" + name
+	}
+
+	return fmt.Sprintf("attachment %s %s desired %d times", KeyForGVK(c.GroupVersionKind), name, len(c.Objects))
+}
+
+// MergeAttachments merges the desired attachments of a DecoratorController over
+// the observed ones, matching them by the GroupVersionKind they are listed
+// under, namespace, and name. Unlike Diff, it does not default namespaces. The
+// result holds every desired attachment, plus each observed attachment that is
+// not desired, so a hook that only computes some of its attachments keeps the
+// rest; drop observed attachments from the result to have them deleted. It is
+// sorted by kind, namespace, and name.
+//
+// An attachment desired more than once is reported as an AttachmentConflict and
+// the last one wins, since Metacontroller would otherwise apply them in an
+// arbitrary order.
+func MergeAttachments(observed, desired map[schema.GroupVersionKind][]client.Object) ([]client.Object, []AttachmentConflict) {
+	merged := indexObjects(observed)
+
+	var conflicts []AttachmentConflict
+	seen := make(map[objectRef][]client.Object)
+	for gvk, objs := range desired {
+		for _, obj := range objs {
+			ref := objectRef{gvk: gvk, namespace: obj.GetNamespace(), name: obj.GetName()}
+			seen[ref] = append(seen[ref], obj)
+			merged[ref] = obj
+		}
+	}
+
+	refs := sortedRefs(merged)
+	attachments := make([]client.Object, 0, len(refs))
+	for _, ref := range refs {
+		attachments = append(attachments, merged[ref])
+		if objs := seen[ref]; len(objs) > 1 {
+			conflicts = append(conflicts, AttachmentConflict{
+				GroupVersionKind: ref.gvk,
+				Namespace:        ref.namespace,
+				Name:             ref.name,
+				Objects:          objs,
+			})
+		}
+	}
+
+	return attachments, conflicts
+}
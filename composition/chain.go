@@ -0,0 +1,66 @@
+package composition
+
+import (
+	"context"
+	"time"
+
+	api "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SyncerMiddleware decorates a Syncer, e.g. to log, time, or validate syncs
+// independently of the HookServer. LogSync is one once instantiated, e.g.
+// LogSync[*v1.Foo].
+type SyncerMiddleware[P client.Object] func(Syncer[P]) Syncer[P]
+
+// Chain returns syncer wrapped with mws. Middleware apply in the order given,
+// so the first one sees the request first and the response last.
+func Chain[P client.Object](syncer Syncer[P], mws ...SyncerMiddleware[P]) Syncer[P] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		syncer = mws[i](syncer)
+	}
+
+	return syncer
+}
+
+// LogSync returns a Syncer that logs each sync at debug level with the logger
+// from LoggerFromContext: the number of observed children before syncer runs,
+// and the number of desired children and whether the parent was finalized, or
+// the error, once it returns.
+func LogSync[P client.Object](syncer Syncer[P]) Syncer[P] {
+	return SyncerFunc[P](func(ctx context.Context, scheme *api.Scheme, req *SyncRequest[P]) (*SyncResponse[P], error) {
+		logger := LoggerFromContext(ctx)
+		observed := 0
+		for _, children := range req.Children {
+			observed += len(children)
+		}
+		logger.DebugContext(ctx, "Sync started", "observedChildren", observed, "finalizing", req.Finalizing)
+
+		resp, err := syncer.Sync(ctx, scheme, req)
+		if err != nil {
+			logger.DebugContext(ctx, "Sync failed", "error", err)
+
+			return resp, err
+		}
+		if resp != nil {
+			logger.DebugContext(ctx, "Sync completed", "desiredChildren", len(resp.Children), "finalized", resp.Finalized)
+		}
+
+		return resp, nil
+	})
+}
+
+// TimeSync returns a SyncerMiddleware that calls observe with the duration and
+// error of each sync, e.g. to record a histogram of Syncer latency that
+// excludes decoding and encoding.
+func TimeSync[P client.Object](observe func(ctx context.Context, d time.Duration, err error)) SyncerMiddleware[P] {
+	return func(syncer Syncer[P]) Syncer[P] {
+		return SyncerFunc[P](func(ctx context.Context, scheme *api.Scheme, req *SyncRequest[P]) (*SyncResponse[P], error) {
+			start := time.Now()
+			resp, err := syncer.Sync(ctx, scheme, req)
+			observe(ctx, time.Since(start), err)
+
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package composition
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ChildrenOf returns the observed children of req whose kind is that of T,
+// asserted to T. T's GroupVersionKind is resolved from scheme, so T must be a
+// pointer to a registered type, e.g.
+//
+//	deployments, err := composition.ChildrenOf[*appsv1.Deployment](req, scheme)
+//
+// It returns an error if T is not registered or a child of T's kind was
+// decoded into another type, as happens with PreserveUnknownFields.
+func ChildrenOf[T client.Object, P client.Object](req *SyncRequest[P], scheme *runtime.Scheme) ([]T, error) {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("ChildrenOf: %T is not a pointer type", zero)
+	}
+	gvk, err := apiutil.GVKForObject(reflect.New(typ.Elem()).Interface().(T), scheme)
+	if err != nil {
+		return nil, fmt.Errorf("ChildrenOf: error resolving kind of %T: %w", zero, err)
+	}
+
+	children := req.Children[gvk]
+	typed := make([]T, 0, len(children))
+	for _, child := range children {
+		t, ok := child.(T)
+		if !ok {
+			return nil, fmt.Errorf("ChildrenOf: child %s is %T, not %T", objectKey(child), child, zero)
+		}
+		typed = append(typed, t)
+	}
+
+	return typed, nil
+}
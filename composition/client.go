@@ -0,0 +1,270 @@
+package composition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	api "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Client calls the sync hook of a running hook server, encoding requests in
+// Metacontroller's wire format and decoding responses with a scheme. It is
+// useful for end-to-end tests of hooks and for chaining hook servers.
+type Client[P client.Object] struct {
+	scheme     *api.Scheme
+	httpClient *http.Client
+	token      string
+	timeout    time.Duration
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*clientConfig)
+
+// clientConfig holds the settings applied by ClientOptions.
+type clientConfig struct {
+	httpClient *http.Client
+	token      string
+	timeout    time.Duration
+}
+
+// WithHTTPClient sets the http.Client used to send requests. (Default: http.DefaultClient)
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = c
+	}
+}
+
+// WithBearerToken sets a bearer token sent in the Authorization header of every request.
+func WithBearerToken(token string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.token = token
+	}
+}
+
+// WithRequestTimeout bounds the duration of each request. (Default: no timeout)
+func WithRequestTimeout(d time.Duration) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.timeout = d
+	}
+}
+
+// NewClient creates a Client that uses scheme to encode requests and decode responses.
+func NewClient[P client.Object](scheme *api.Scheme, opts ...ClientOption) *Client[P] {
+	cfg := clientConfig{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Client[P]{
+		scheme:     scheme,
+		httpClient: cfg.httpClient,
+		token:      cfg.token,
+		timeout:    cfg.timeout,
+	}
+}
+
+// Sync sends req to the sync hook at url and returns the decoded response.
+// Desired children whose kind is not registered in the scheme are returned as
+// *unstructured.Unstructured.
+func (c *Client[P]) Sync(ctx context.Context, url string, req *SyncRequest[P]) (*SyncResponse[P], error) {
+	body, err := EncodeSyncRequest(c.scheme, req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := c.post(ctx, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeSyncResponse[P](c.scheme, respBody)
+}
+
+// post sends body to url and returns the response body of a 2xx response.
+func (c *Client[P]) post(ctx context.Context, url string, body []byte) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("hook responded %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	return respBody, nil
+}
+
+// EncodeSyncRequest encodes req in Metacontroller's wire format, as sent to a
+// sync hook. The parent's apiVersion and kind are resolved from scheme, and
+// children and related objects get those of the kind they are listed under.
+func EncodeSyncRequest[P client.Object](scheme *api.Scheme, req *SyncRequest[P]) ([]byte, error) {
+	parentGVK, err := apiutil.GVKForObject(req.Parent, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving kind of parent: %w", err)
+	}
+	parent, err := marshalWithKind(req.Parent, parentGVK)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding parent: %w", err)
+	}
+
+	children, err := marshalObjectMap(req.Children)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding children: %w", err)
+	}
+
+	body := map[string]any{
+		"parent":   parent,
+		"children": children,
+	}
+	if len(req.Related) > 0 {
+		related, err := marshalObjectMap(req.Related)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding related objects: %w", err)
+		}
+		body["related"] = related
+	}
+	if req.Finalizing {
+		body["finalizing"] = true
+	}
+	if len(req.Controller) > 0 {
+		body["controller"] = req.Controller
+	}
+
+	return json.Marshal(body)
+}
+
+// marshalObjectMap encodes objects grouped by GroupVersionKind as the nested
+// map Metacontroller uses for children and related objects.
+func marshalObjectMap(objects map[schema.GroupVersionKind][]client.Object) (map[string]map[string]json.RawMessage, error) {
+	m := make(map[string]map[string]json.RawMessage, len(objects))
+	for gvk, objs := range objects {
+		key := KeyForGVK(gvk)
+		m[key] = make(map[string]json.RawMessage, len(objs))
+		for _, obj := range objs {
+			data, err := marshalWithKind(obj, gvk)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", key, objectKey(obj), err)
+			}
+			m[key][objectKey(obj)] = data
+		}
+	}
+
+	return m, nil
+}
+
+// DecodeSyncResponse decodes the body of a sync hook response with scheme. The
+// status is decoded as P, and desired children whose kind is not registered in
+// the scheme are returned as *unstructured.Unstructured. Client and the
+// metacontrollertest package decode responses with it.
+func DecodeSyncResponse[P client.Object](scheme *api.Scheme, body []byte) (*SyncResponse[P], error) {
+	var raw struct {
+		Status             json.RawMessage   `json:"status"`
+		Children           []json.RawMessage `json:"children"`
+		ChildPatches       []ChildPatch      `json:"childPatches"`
+		Finalized          bool              `json:"finalized"`
+		ResyncAfterSeconds float64           `json:"resyncAfterSeconds"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	resp := &SyncResponse[P]{
+		ChildPatches: raw.ChildPatches,
+		Finalized:    raw.Finalized,
+		ResyncAfter:  time.Duration(raw.ResyncAfterSeconds * float64(time.Second)),
+	}
+	if len(raw.Status) > 0 {
+		var statusDecoder api.Decoder = decoder
+		if _, ok := any(resp.Status).(*unstructured.Unstructured); ok {
+			statusDecoder = unstructured.UnstructuredJSONScheme
+		}
+		obj, err := decodeObject(statusDecoder, raw.Status)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding status: %w", err)
+		}
+		status, ok := obj.(P)
+		if !ok {
+			return nil, fmt.Errorf("status is %T, not %T", obj, resp.Status)
+		}
+		resp.Status = status
+	}
+
+	for _, rawChild := range raw.Children {
+		child, err := decodeObject(decoder, rawChild)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding child: %w", err)
+		}
+		resp.Children = append(resp.Children, child)
+	}
+
+	return resp, nil
+}
+
+// marshalWithKind encodes a copy of obj as JSON with the given apiVersion and kind.
+func marshalWithKind(obj client.Object, gvk schema.GroupVersionKind) (json.RawMessage, error) {
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	return json.Marshal(obj)
+}
+
+// decodeObject decodes data with decoder, falling back to unstructured decoding
+// for kinds that are not registered in the scheme.
+func decodeObject(decoder api.Decoder, data []byte) (client.Object, error) {
+	obj, _, err := decoder.Decode(data, nil, nil)
+	if api.IsNotRegisteredError(err) {
+		obj, _, err = unstructured.UnstructuredJSONScheme.Decode(data, nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a client.Object", obj)
+	}
+
+	return o, nil
+}
+
+// objectKey returns the key Metacontroller uses for obj within a children map:
+// "namespace/name" for namespaced objects and "name" otherwise.
+func objectKey(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,50 @@
+package composition
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDecodeSyncResponse(t *testing.T) {
+	body := []byte(`{
+		"status":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"parent"},"data":{"k":"v"}},
+		"children":[
+			{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s","namespace":"default"}},
+			{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}
+		],
+		"finalized":true,
+		"resyncAfterSeconds":1.5}`)
+
+	resp, err := DecodeSyncResponse[*corev1.ConfigMap](testScheme(t), body)
+	if err != nil {
+		t.Fatalf("DecodeSyncResponse() error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.Data["k"] != "v" {
+		t.Errorf("Status = %v, want ConfigMap parent with k=v", resp.Status)
+	}
+	if len(resp.Children) != 2 {
+		t.Fatalf("got %d children, want 2", len(resp.Children))
+	}
+	if _, ok := resp.Children[0].(*corev1.Secret); !ok {
+		t.Errorf("Children[0] is %T, want *corev1.Secret", resp.Children[0])
+	}
+	if _, ok := resp.Children[1].(*unstructured.Unstructured); !ok {
+		t.Errorf("Children[1] is %T, want *unstructured.Unstructured for an unregistered kind", resp.Children[1])
+	}
+	if !resp.Finalized || resp.ResyncAfter != 1500*time.Millisecond {
+		t.Errorf("Finalized, ResyncAfter = %v, %v, want true, 1.5s", resp.Finalized, resp.ResyncAfter)
+	}
+}
+
+func TestDecodeSyncResponseWithoutStatus(t *testing.T) {
+	resp, err := DecodeSyncResponse[*corev1.ConfigMap](testScheme(t), []byte(`{"children":[]}`))
+	if err != nil {
+		t.Fatalf("DecodeSyncResponse() error = %v", err)
+	}
+	if resp.Status != nil {
+		t.Errorf("Status = %v, want nil when the response has no status", resp.Status)
+	}
+}
@@ -0,0 +1,20 @@
+package composition
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetCondition adds or updates cond in conds, following meta.SetStatusCondition
+// semantics: an existing condition of the same type is updated in place, and its
+// LastTransitionTime only changes when its Status changes. A zero
+// LastTransitionTime on a new or transitioning condition is set to the current
+// time. It reports whether conds was modified.
+func SetCondition(conds *[]metav1.Condition, cond metav1.Condition) bool {
+	return meta.SetStatusCondition(conds, cond)
+}
+
+// GetCondition returns the condition of the given type, or nil if conds has none.
+func GetCondition(conds []metav1.Condition, condType string) *metav1.Condition {
+	return meta.FindStatusCondition(conds, condType)
+}
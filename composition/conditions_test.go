@@ -0,0 +1,74 @@
+package composition
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	then := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ready := func(status metav1.ConditionStatus, reason string) metav1.Condition {
+		return metav1.Condition{Type: "Ready", Status: status, Reason: reason, LastTransitionTime: then}
+	}
+
+	tests := []struct {
+		name        string
+		conds       []metav1.Condition
+		cond        metav1.Condition
+		wantChanged bool
+		wantReason  string
+		// wantTransitioned is whether LastTransitionTime moves off then.
+		wantTransitioned bool
+	}{
+		{
+			name:        "insert",
+			cond:        ready(metav1.ConditionTrue, "Up"),
+			wantChanged: true,
+			wantReason:  "Up",
+		},
+		{
+			name:             "update status",
+			conds:            []metav1.Condition{ready(metav1.ConditionFalse, "Down")},
+			cond:             metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Up"},
+			wantChanged:      true,
+			wantReason:       "Up",
+			wantTransitioned: true,
+		},
+		{
+			name:        "update reason only",
+			conds:       []metav1.Condition{ready(metav1.ConditionTrue, "Up")},
+			cond:        metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "StillUp"},
+			wantChanged: true,
+			wantReason:  "StillUp",
+		},
+		{
+			name:       "no-op",
+			conds:      []metav1.Condition{ready(metav1.ConditionTrue, "Up")},
+			cond:       metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Up"},
+			wantReason: "Up",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds := tt.conds
+			if changed := SetCondition(&conds, tt.cond); changed != tt.wantChanged {
+				t.Errorf("SetCondition() = %v, want %v", changed, tt.wantChanged)
+			}
+			if len(conds) != 1 {
+				t.Fatalf("got %d conditions, want 1", len(conds))
+			}
+			got := GetCondition(conds, "Ready")
+			if got == nil {
+				t.Fatal("GetCondition() = nil")
+			}
+			if got.Status != tt.cond.Status || got.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s, want %s/%s", got.Status, got.Reason, tt.cond.Status, tt.wantReason)
+			}
+			if transitioned := !got.LastTransitionTime.Equal(&then); transitioned != tt.wantTransitioned {
+				t.Errorf("LastTransitionTime = %v, want it changed from %v: %v", got.LastTransitionTime, then, tt.wantTransitioned)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package composition
+
+import (
+	"context"
+	"crypto/x509"
+	"log/slog"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// loggerKey is the context key for the request-scoped logger.
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or slog.Default() if
+// there is none. Within a hook it returns the HookServer's logger annotated with
+// the hook type and the parent's namespace, name, and UID, so log lines can be
+// correlated with the reconcile that produced them.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// parentKey is the context key for the decoded parent of a hook request.
+type parentKey struct{}
+
+// WithParent returns a copy of ctx carrying parent.
+func WithParent(ctx context.Context, parent client.Object) context.Context {
+	return context.WithValue(ctx, parentKey{}, parent)
+}
+
+// ParentFromContext returns the decoded parent of the hook request ctx belongs
+// to. The parent is decoded before middleware registered with Use run, so
+// middleware (e.g. for authorization based on a tenant label) can inspect it
+// without decoding the request again. It returns false if the parent could not
+// be decoded.
+func ParentFromContext(ctx context.Context) (client.Object, bool) {
+	parent, ok := ctx.Value(parentKey{}).(client.Object)
+
+	return parent, ok
+}
+
+// peerCertKey is the context key for the verified TLS client certificate.
+type peerCertKey struct{}
+
+// WithPeerCert returns a copy of ctx carrying cert.
+func WithPeerCert(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, peerCertKey{}, cert)
+}
+
+// PeerCertFromContext returns the verified client certificate of the hook
+// request ctx belongs to, so middleware or hooks can authorize callers by its
+// subject or SANs. It returns false unless the request was made over TLS with a
+// client certificate the server verified, which requires the server's
+// tls.Config.ClientAuth to be tls.VerifyClientCertIfGiven or
+// tls.RequireAndVerifyClientCert, with ClientCAs set.
+func PeerCertFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(peerCertKey{}).(*x509.Certificate)
+
+	return cert, ok
+}
@@ -0,0 +1,155 @@
+package composition
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CompositeController is the Metacontroller CompositeController that invoked a
+// hook. It models the fields of the metacontroller.k8s.io/v1alpha1 resource that
+// hooks commonly need; unknown fields are ignored when decoding.
+type CompositeController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CompositeControllerSpec `json:"spec"`
+}
+
+// CompositeControllerSpec is the spec of a CompositeController.
+type CompositeControllerSpec struct {
+	// ParentResource is the resource the controller manages.
+	ParentResource ParentResourceRule `json:"parentResource"`
+	// ChildResources are the resources the controller creates for each parent.
+	ChildResources []ChildResourceRule `json:"childResources,omitempty"`
+	// Hooks configures the controller's webhooks.
+	Hooks *CompositeControllerHooks `json:"hooks,omitempty"`
+	// ResyncPeriodSeconds is how often every parent is resynced, if set.
+	ResyncPeriodSeconds *int32 `json:"resyncPeriodSeconds,omitempty"`
+	// GenerateSelector indicates that Metacontroller generates the parent's
+	// label selector.
+	GenerateSelector *bool `json:"generateSelector,omitempty"`
+}
+
+// ParentResourceRule identifies the parent resource of a CompositeController.
+type ParentResourceRule struct {
+	// APIVersion is the API version (e.g., "example.com/v1").
+	APIVersion string `json:"apiVersion"`
+	// Resource is the canonical, lowercase, plural name of the resource.
+	Resource string `json:"resource"`
+	// LabelSelector, if set, restricts the parents the controller manages.
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// ChildResourceRule identifies a child resource of a CompositeController.
+type ChildResourceRule struct {
+	// APIVersion is the API version (e.g., "apps/v1").
+	APIVersion string `json:"apiVersion"`
+	// Resource is the canonical, lowercase, plural name of the resource.
+	Resource string `json:"resource"`
+	// UpdateStrategy, if set, is how Metacontroller updates existing children.
+	UpdateStrategy *ChildUpdateStrategy `json:"updateStrategy,omitempty"`
+}
+
+// ChildUpdateStrategy is the update strategy of a child resource.
+type ChildUpdateStrategy struct {
+	// Method is the update method, e.g. "OnDelete", "Recreate", "InPlace",
+	// "RollingRecreate", or "RollingInPlace".
+	Method string `json:"method,omitempty"`
+}
+
+// CompositeControllerHooks configures the webhooks of a CompositeController.
+type CompositeControllerHooks struct {
+	Sync      *Hook `json:"sync,omitempty"`
+	Finalize  *Hook `json:"finalize,omitempty"`
+	Customize *Hook `json:"customize,omitempty"`
+}
+
+// Hook configures a single CompositeController hook.
+type Hook struct {
+	Webhook *Webhook `json:"webhook,omitempty"`
+}
+
+// Webhook configures how Metacontroller calls a hook. Either URL or Service is
+// set.
+type Webhook struct {
+	// URL is the full URL of the hook.
+	URL *string `json:"url,omitempty"`
+	// Path is the URL path of the hook on Service.
+	Path *string `json:"path,omitempty"`
+	// Timeout is how long Metacontroller waits for the hook to respond.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Service is the in-cluster Service serving the hook.
+	Service *ServiceReference `json:"service,omitempty"`
+}
+
+// ServiceReference identifies the Service serving a hook.
+type ServiceReference struct {
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace"`
+	Port      *int32  `json:"port,omitempty"`
+	Protocol  *string `json:"protocol,omitempty"`
+}
+
+// DefaultWebhookTimeout is the timeout Metacontroller applies to webhooks that
+// do not configure one.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Timeout returns the webhook timeout configured for the hook, or
+// DefaultWebhookTimeout if none is. A nil Hook has the default timeout.
+func (h *Hook) Timeout() time.Duration {
+	if h == nil || h.Webhook == nil || h.Webhook.Timeout == nil {
+		return DefaultWebhookTimeout
+	}
+
+	return h.Webhook.Timeout.Duration
+}
+
+// DeadlineHint returns how long Metacontroller waits for the sync hook before
+// abandoning the request, as configured on the controller. Hooks doing external
+// I/O can use it to budget their work; the request context is canceled when
+// Metacontroller disconnects, and also carries a deadline when the HookServer
+// sets a HookTimeout.
+func (r *SyncRequest[P]) DeadlineHint() (time.Duration, error) {
+	cc, err := r.DecodeController()
+	if err != nil {
+		return 0, err
+	}
+	if cc.Spec.Hooks == nil {
+		return DefaultWebhookTimeout, nil
+	}
+
+	return cc.Spec.Hooks.Sync.Timeout(), nil
+}
+
+// DecodeController decodes the request's Controller.
+func (r *CustomizeRequest[P]) DecodeController() (*CompositeController, error) {
+	return decodeController(r.Controller)
+}
+
+// DecodeController decodes the request's Controller.
+func (r *SyncRequest[P]) DecodeController() (*CompositeController, error) {
+	return decodeController(r.Controller)
+}
+
+// DecodeController decodes the request's Controller.
+func (r *FinalizeRequest[P]) DecodeController() (*CompositeController, error) {
+	return decodeController(r.Controller)
+}
+
+// decodeController decodes the raw JSON of a CompositeController.
+func decodeController(raw json.RawMessage) (*CompositeController, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("request has no controller")
+	}
+
+	cc := &CompositeController{}
+	if err := json.Unmarshal(raw, cc); err != nil {
+		return nil, fmt.Errorf("error decoding controller: %w", err)
+	}
+
+	return cc, nil
+}
@@ -3,6 +3,8 @@ package composition
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -12,6 +14,7 @@ import (
 // Request represents the customize hook request. It contains the full CompositeController object (as raw JSON) and the parent object.
 type CustomizeRequest[P client.Object] struct {
 	// Controller is the full CompositeController object as received.
+	// DecodeController decodes it into a CompositeController.
 	Controller json.RawMessage `json:"controller"`
 	// Parent is the parent resource.
 	Parent P `json:"parent"`
@@ -37,9 +40,24 @@ type CustomizeResponse struct {
 	RelatedResources []ResourceRule `json:"relatedResources"`
 }
 
+// Validate checks every rule with ResourceRule.Validate. The returned error
+// describes every problem found.
+func (r *CustomizeResponse) Validate() error {
+	var errs []error
+	for i, rule := range r.RelatedResources {
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("related resource %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // Customizer is an interface for processing customize hook requests.
 type Customizer[P client.Object] interface {
 	// Customize is a function that processes customize requests. It receives a context, the runtime scheme, and a decoded customize request, then returns a customize response or an error.
+	// The context is canceled when Metacontroller abandons the request (e.g. after
+	// its webhook timeout), so long-running work should honor ctx.Done().
 	Customize(
 		ctx context.Context,
 		scheme *runtime.Scheme,
@@ -58,3 +76,14 @@ type CustomizeFunc[P client.Object] func(
 func (fn CustomizeFunc[P]) Customize(ctx context.Context, scheme *runtime.Scheme, req *CustomizeRequest[P]) (*CustomizeResponse, error) {
 	return fn(ctx, scheme, req)
 }
+
+// CustomizeFromRefs returns a Customizer for the common case of parents that
+// reference related objects from their spec. extract returns the rules for a
+// parent, typically one per reference; rules selecting the same resource are
+// merged as by CustomizeResponseBuilder. A nil or empty result selects no
+// related resources.
+func CustomizeFromRefs[P client.Object](extract func(parent P) []ResourceRule) Customizer[P] {
+	return CustomizeFunc[P](func(_ context.Context, scheme *runtime.Scheme, req *CustomizeRequest[P]) (*CustomizeResponse, error) {
+		return NewCustomizeResponseBuilder(scheme).Add(extract(req.Parent)...).Build()
+	})
+}
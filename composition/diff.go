@@ -0,0 +1,124 @@
+package composition
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	api "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// objectRef identifies an object by GroupVersionKind, namespace, and name.
+type objectRef struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// compare orders objectRefs by GroupVersionKind key, namespace, and name.
+func (r objectRef) compare(o objectRef) int {
+	return cmp.Or(
+		cmp.Compare(KeyForGVK(r.gvk), KeyForGVK(o.gvk)),
+		cmp.Compare(r.namespace, o.namespace),
+		cmp.Compare(r.name, o.name),
+	)
+}
+
+// Diff compares observed and desired children, matching them by
+// GroupVersionKind, namespace, and name. It returns the desired children that
+// are not observed (create), the desired children that are also observed
+// (update), and the observed children that are no longer desired (delete).
+//
+// Kinds are resolved from scheme, as in DefaultNamespace, so typed children
+// with an empty TypeMeta are matched by their registered kind. Desired children
+// of namespaced kinds that have no namespace are matched as if they were in
+// namespace, usually the parent's, the way Metacontroller defaults them when
+// it applies them; scopes are determined with IsNamespaced and mapper, which
+// may be nil. Cluster-scoped children keep an empty namespace and match only
+// other cluster-scoped children. The children are not modified.
+//
+// Each result is sorted by kind, namespace, and name; when an object appears
+// more than once under the same key, the last one wins. The returned error
+// names each child whose kind or scope could not be determined; such children
+// are left out of the results.
+func Diff(scheme *api.Scheme, mapper meta.RESTMapper, namespace string, observed, desired map[schema.GroupVersionKind][]client.Object) (create, update, delete []client.Object, err error) {
+	observedByRef, observedErr := indexChildren(scheme, mapper, "", observed)
+	desiredByRef, desiredErr := indexChildren(scheme, mapper, namespace, desired)
+
+	for _, ref := range sortedRefs(desiredByRef) {
+		if _, ok := observedByRef[ref]; ok {
+			update = append(update, desiredByRef[ref])
+		} else {
+			create = append(create, desiredByRef[ref])
+		}
+	}
+	for _, ref := range sortedRefs(observedByRef) {
+		if _, ok := desiredByRef[ref]; !ok {
+			delete = append(delete, observedByRef[ref])
+		}
+	}
+
+	return create, update, delete, errors.Join(observedErr, desiredErr)
+}
+
+// indexChildren indexes objects by their objectRef, resolving kinds from scheme.
+// If namespace is not empty, it is the namespace of namespaced objects that
+// have none.
+func indexChildren(scheme *api.Scheme, mapper meta.RESTMapper, namespace string, objects map[schema.GroupVersionKind][]client.Object) (map[objectRef]client.Object, error) {
+	index := make(map[objectRef]client.Object)
+	var errs []error
+	for _, objs := range objects {
+		for _, obj := range objs {
+			gvk, err := apiutil.GVKForObject(obj, scheme)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("child %s: %w", objectKey(obj), err))
+
+				continue
+			}
+			ns := obj.GetNamespace()
+			if ns == "" && namespace != "" {
+				namespaced, err := IsNamespaced(mapper, gvk)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("child %s %s: %w", gvk.Kind, obj.GetName(), err))
+
+					continue
+				}
+				if namespaced {
+					ns = namespace
+				}
+			}
+			index[objectRef{gvk: gvk, namespace: ns, name: obj.GetName()}] = obj
+		}
+	}
+
+	return index, errors.Join(errs...)
+}
+
+// indexObjects indexes objects by their objectRef, taking each object's kind
+// from the map key it is listed under.
+func indexObjects(objects map[schema.GroupVersionKind][]client.Object) map[objectRef]client.Object {
+	index := make(map[objectRef]client.Object)
+	for gvk, objs := range objects {
+		for _, obj := range objs {
+			index[objectRef{gvk: gvk, namespace: obj.GetNamespace(), name: obj.GetName()}] = obj
+		}
+	}
+
+	return index
+}
+
+// sortedRefs returns the keys of index in order.
+func sortedRefs(index map[objectRef]client.Object) []objectRef {
+	refs := make([]objectRef, 0, len(index))
+	for ref := range index {
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, objectRef.compare)
+
+	return refs
+}
@@ -0,0 +1,153 @@
+package composition
+
+import (
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// testScheme returns a scheme with the core/v1 types registered.
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return scheme
+}
+
+// refNames returns the "kind namespace/name" of each object, resolving kinds
+// from scheme.
+func refNames(t *testing.T, scheme *runtime.Scheme, objs []client.Object) []string {
+	t.Helper()
+	var names []string
+	for _, obj := range objs {
+		gvks, _, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, gvks[0].Kind+" "+obj.GetNamespace()+"/"+obj.GetName())
+	}
+
+	return names
+}
+
+func TestDiff(t *testing.T) {
+	secrets := corev1.SchemeGroupVersion.WithKind("Secret")
+	configMaps := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	namespaces := corev1.SchemeGroupVersion.WithKind("Namespace")
+	secret := func(ns, name string) client.Object {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	configMap := func(ns, name string) client.Object {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	namespace := func(name string) client.Object {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	tests := []struct {
+		name       string
+		mapper     meta.RESTMapper
+		observed   map[schema.GroupVersionKind][]client.Object
+		desired    map[schema.GroupVersionKind][]client.Object
+		wantCreate []string
+		wantUpdate []string
+		wantDelete []string
+	}{
+		{
+			name:       "create, update, and delete",
+			observed:   map[schema.GroupVersionKind][]client.Object{secrets: {secret("default", "a"), secret("default", "b")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{secrets: {secret("default", "b"), secret("default", "c")}},
+			wantCreate: []string{"Secret default/c"},
+			wantUpdate: []string{"Secret default/b"},
+			wantDelete: []string{"Secret default/a"},
+		},
+		{
+			name:       "desired namespace defaulted",
+			observed:   map[schema.GroupVersionKind][]client.Object{secrets: {secret("default", "a")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{secrets: {secret("", "a"), secret("", "b")}},
+			wantCreate: []string{"Secret /b"},
+			wantUpdate: []string{"Secret /a"},
+		},
+		{
+			name:       "other namespace not matched",
+			observed:   map[schema.GroupVersionKind][]client.Object{secrets: {secret("other", "a")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{secrets: {secret("", "a")}},
+			wantCreate: []string{"Secret /a"},
+			wantDelete: []string{"Secret other/a"},
+		},
+		{
+			name:       "cluster-scoped children keep an empty namespace",
+			observed:   map[schema.GroupVersionKind][]client.Object{namespaces: {namespace("a"), namespace("b")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{namespaces: {namespace("a")}},
+			wantUpdate: []string{"Namespace /a"},
+			wantDelete: []string{"Namespace /b"},
+		},
+		{
+			name: "cluster-scoped by RESTMapper",
+			mapper: func() meta.RESTMapper {
+				mapper := meta.NewDefaultRESTMapper(nil)
+				mapper.Add(secrets, meta.RESTScopeRoot)
+
+				return mapper
+			}(),
+			observed:   map[schema.GroupVersionKind][]client.Object{secrets: {secret("", "a")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{secrets: {secret("", "a")}},
+			wantUpdate: []string{"Secret /a"},
+		},
+		{
+			name:       "same name, different kinds",
+			observed:   map[schema.GroupVersionKind][]client.Object{secrets: {secret("default", "a")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{configMaps: {configMap("default", "a")}},
+			wantCreate: []string{"ConfigMap default/a"},
+			wantDelete: []string{"Secret default/a"},
+		},
+		{
+			name:       "kinds resolved from the scheme",
+			observed:   map[schema.GroupVersionKind][]client.Object{{}: {secret("default", "a")}},
+			desired:    map[schema.GroupVersionKind][]client.Object{secrets: {secret("default", "a")}},
+			wantUpdate: []string{"Secret default/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			create, update, del, err := Diff(scheme, tt.mapper, "default", tt.observed, tt.desired)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if got := refNames(t, scheme, create); !slices.Equal(got, tt.wantCreate) {
+				t.Errorf("create = %v, want %v", got, tt.wantCreate)
+			}
+			if got := refNames(t, scheme, update); !slices.Equal(got, tt.wantUpdate) {
+				t.Errorf("update = %v, want %v", got, tt.wantUpdate)
+			}
+			if got := refNames(t, scheme, del); !slices.Equal(got, tt.wantDelete) {
+				t.Errorf("delete = %v, want %v", got, tt.wantDelete)
+			}
+		})
+	}
+}
+
+func TestDiffUnresolvableKind(t *testing.T) {
+	scheme := runtime.NewScheme()
+	desired := map[schema.GroupVersionKind][]client.Object{
+		corev1.SchemeGroupVersion.WithKind("Secret"): {&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "a"}}},
+	}
+
+	create, _, _, err := Diff(scheme, nil, "default", nil, desired)
+	if err == nil {
+		t.Error("Diff() error = nil, want an error for a kind missing from the scheme")
+	}
+	if len(create) != 0 {
+		t.Errorf("create = %v, want the unresolved child left out", create)
+	}
+}
@@ -0,0 +1,5 @@
+// Package composition defines the request and response types, handler
+// interfaces, and helpers for CompositeController hooks. It is the single
+// canonical home for these types: the metacontroller package decodes hook
+// requests into them and encodes hook responses from them.
+package composition
@@ -0,0 +1,66 @@
+package composition
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrRetryLater indicates a transient failure. A hook returning an error
+	// that wraps it is answered with 429 Too Many Requests, so Metacontroller
+	// backs off and retries the parent.
+	ErrRetryLater = errors.New("retry later")
+	// ErrBadRequest indicates that the request cannot be processed, e.g.
+	// because the parent's spec is invalid. A hook returning an error that
+	// wraps it is answered with 400 Bad Request.
+	ErrBadRequest = errors.New("bad request")
+)
+
+// HTTPStatus returns the HTTP status code the HookServer responds with when a
+// hook returns err. Errors implementing interface{ HTTPStatus() int } choose
+// their own status (statuses outside 400-599 are treated as 500); errors
+// wrapping ErrRetryLater or ErrBadRequest map to 429 and 400; anything else is
+// 500 Internal Server Error.
+func HTTPStatus(err error) int {
+	var statusErr interface{ HTTPStatus() int }
+	switch {
+	case errors.As(err, &statusErr):
+		if code := statusErr.HTTPStatus(); code >= 400 && code <= 599 {
+			return code
+		}
+
+		return http.StatusInternalServerError
+	case errors.Is(err, ErrRetryLater):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// DeferError is returned by Defer.
+type DeferError struct {
+	// After is how long Metacontroller should wait before syncing the parent
+	// again.
+	After time.Duration
+}
+
+// Defer returns an error that makes a sync hook respond without changes: the
+// parent's status is left untouched, every observed child is returned as
+// desired so none are pruned, and Metacontroller is asked to sync the parent
+// again after d. The children are returned without their status and the
+// metadata populated by the API server, such as resourceVersion and uid. If
+// some observed children could not be decoded, the hook fails with 500 instead,
+// since they would be pruned. Use it when the hook cannot decide on children
+// yet and wants to wait rather than fail.
+func Defer(d time.Duration) error {
+	return &DeferError{After: d}
+}
+
+// Error implements error.
+func (e *DeferError) Error() string {
+	return fmt.Sprintf("sync deferred for %s", e.After)
+}
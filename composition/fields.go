@@ -0,0 +1,123 @@
+package composition
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Field returns the value at path in obj as a T, e.g.
+// Field[int64](parent, "spec.replicas") or Field[string](parent, "spec.image").
+// path is a dot-separated list of field names with an optional leading dot, so
+// keys that contain dots cannot be addressed. It reports false if the field is
+// absent or null. Numbers are converted between int, int32, int64, float32, and
+// float64, as long as the value fits in T without loss; any other type mismatch
+// is an error. Maps and slices are returned without copying, so do not modify
+// them.
+func Field[T any](obj *unstructured.Unstructured, path string) (T, bool, error) {
+	var zero T
+	fields := strings.Split(strings.TrimPrefix(path, "."), ".")
+	val, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	if err != nil {
+		return zero, false, fmt.Errorf("error reading field %s: %w", path, err)
+	}
+	if !found || val == nil {
+		return zero, false, nil
+	}
+	if v, ok := val.(T); ok {
+		return v, true, nil
+	}
+
+	v, err := convertNumber[T](val)
+	if err != nil {
+		return zero, true, fmt.Errorf("field %s: %w", path, err)
+	}
+
+	return v, true, nil
+}
+
+// convertNumber converts the number val to T if T is a numeric type.
+func convertNumber[T any](val any) (T, error) {
+	var out T
+	switch p := any(&out).(type) {
+	case *int64:
+		n, err := toInt64(val)
+		*p = n
+
+		return out, err
+	case *int:
+		n, err := toInt64(val)
+		if err == nil && int64(int(n)) != n {
+			err = fmt.Errorf("%d overflows int", n)
+		}
+		*p = int(n)
+
+		return out, err
+	case *int32:
+		n, err := toInt64(val)
+		if err == nil && (n < math.MinInt32 || n > math.MaxInt32) {
+			err = fmt.Errorf("%d overflows int32", n)
+		}
+		*p = int32(n)
+
+		return out, err
+	case *float64:
+		f, err := toFloat64(val)
+		*p = f
+
+		return out, err
+	case *float32:
+		f, err := toFloat64(val)
+		if err == nil && float64(float32(f)) != f {
+			err = fmt.Errorf("%v does not fit in float32", f)
+		}
+		*p = float32(f)
+
+		return out, err
+	}
+
+	return out, fmt.Errorf("expected %T, got %T", out, val)
+}
+
+// toInt64 converts a JSON number to an int64, failing if it is not integral.
+func toInt64(val any) (int64, error) {
+	switch v := val.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	}
+
+	return 0, fmt.Errorf("expected a number, got %T", val)
+}
+
+// toFloat64 converts a JSON number to a float64.
+func toFloat64(val any) (float64, error) {
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	}
+
+	return 0, fmt.Errorf("expected a number, got %T", val)
+}
@@ -0,0 +1,34 @@
+package composition
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFieldFloat32(t *testing.T) {
+	tests := []struct {
+		value   any
+		want    float32
+		wantErr bool
+	}{
+		{value: 1.5, want: 1.5},
+		{value: int64(3), want: 3},
+		{value: 0.1, wantErr: true},
+		{value: 1e39, wantErr: true},
+		{value: int64(1<<24 + 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		obj := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"ratio": tt.value}}}
+		got, found, err := Field[float32](obj, "spec.ratio")
+		if !found {
+			t.Errorf("Field(%v) found = false, want true", tt.value)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Field(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("Field(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package composition
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
 
 	api "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -11,15 +17,24 @@ import (
 
 // FinalizeRequest represents the fully decoded finalize hook request.
 type FinalizeRequest[P client.Object] struct {
+	// Controller is the CompositeController that invoked the hook, as raw JSON.
+	// DecodeController decodes it into a CompositeController.
+	Controller json.RawMessage
 	// Parent is the composite (parent) resource.
 	Parent P
-	// Children is a map from GroupVersionKind to slices of decoded child objects.
+	// Children is a map from GroupVersionKind to slices of decoded child
+	// objects. Each slice is sorted by namespace, then by name.
 	Children map[schema.GroupVersionKind][]client.Object
+	// Related is a map from GroupVersionKind to slices of decoded related
+	// objects, as selected by the customize hook, e.g. Secrets to detach
+	// before deletion finishes.
+	Related map[schema.GroupVersionKind][]client.Object
 }
 
 // FinalizeResponse represents the finalize hook response.
 type FinalizeResponse[P client.Object] struct {
-	// Status is the updated composite (parent) resource.
+	// Status is the updated composite (parent) resource. A nil Status leaves
+	// the parent's status untouched.
 	Status P
 	// Children defines the desired state for child objects.
 	Children map[schema.GroupVersionKind][]client.Object
@@ -27,11 +42,33 @@ type FinalizeResponse[P client.Object] struct {
 	Finalized bool
 }
 
+// Validate checks that the response can be encoded, as SyncResponse.Validate
+// does: every child must be non-nil, have a name, and have a GroupVersionKind
+// that resolves from the scheme. The returned error describes every problem
+// found. A nil Status is valid and leaves the parent's status untouched.
+func (r *FinalizeResponse[P]) Validate(scheme *api.Scheme) error {
+	var errs []error
+	for _, gvk := range slices.SortedFunc(maps.Keys(r.Children), compareGVKs) {
+		for i, child := range r.Children[gvk] {
+			errs = append(errs, validateChild(scheme, fmt.Sprintf("child %s %d", KeyForGVK(gvk), i), child)...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// compareGVKs orders GroupVersionKinds by key.
+func compareGVKs(a, b schema.GroupVersionKind) int {
+	return cmp.Compare(KeyForGVK(a), KeyForGVK(b))
+}
+
 // Finalizer is an interface for processing finalize requests.
 type Finalizer[P client.Object] interface {
 	// Finalize is a function that processes finalize requests.
 	// It receives a context, the runtime scheme, and a decoded finalize request,
 	// then returns a finalize response or an error.
+	// The context is canceled when Metacontroller abandons the request (e.g. after
+	// its webhook timeout), so long-running work should honor ctx.Done().
 	Finalize(
 		ctx context.Context,
 		scheme *api.Scheme,
@@ -50,3 +87,58 @@ type FinalizeFunc[P client.Object] func(
 func (fn FinalizeFunc[P]) Finalize(ctx context.Context, scheme *api.Scheme, req *FinalizeRequest[P]) (*FinalizeResponse[P], error) {
 	return fn(ctx, scheme, req)
 }
+
+// StatusFinalizeFunc is a Finalizer for controllers without children to clean
+// up: it returns only the parent's status and whether finalization is
+// complete, and the response carries no desired children. A nil status leaves
+// the parent's status untouched.
+type StatusFinalizeFunc[P client.Object] func(
+	ctx context.Context,
+	scheme *api.Scheme,
+	req *FinalizeRequest[P],
+) (status P, finalized bool, err error)
+
+// Finalize implements the Finalizer interface.
+func (fn StatusFinalizeFunc[P]) Finalize(ctx context.Context, scheme *api.Scheme, req *FinalizeRequest[P]) (*FinalizeResponse[P], error) {
+	status, finalized, err := fn(ctx, scheme, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FinalizeResponse[P]{Status: status, Finalized: finalized}, nil
+}
+
+// FinalizeWhenEmpty returns a Finalizer implementing the usual finalization
+// loop: the response always has no desired children, so Metacontroller keeps
+// deleting every observed child on each call, and the parent is marked
+// Finalized once no children remain. If finalizer is non-nil it is called first
+// to compute the status and perform any other cleanup; the Children it returns
+// are discarded, so none of them is kept, and its Finalized is overridden. If
+// finalizer is nil, or returns a nil response, the parent is returned unchanged
+// as the status.
+func FinalizeWhenEmpty[P client.Object](finalizer Finalizer[P]) Finalizer[P] {
+	return FinalizeFunc[P](func(ctx context.Context, scheme *api.Scheme, req *FinalizeRequest[P]) (*FinalizeResponse[P], error) {
+		resp := &FinalizeResponse[P]{Status: req.Parent}
+		if finalizer != nil {
+			custom, err := finalizer.Finalize(ctx, scheme, req)
+			if err != nil {
+				return nil, err
+			}
+			if custom != nil {
+				resp = custom
+			}
+		}
+
+		resp.Children = nil
+		resp.Finalized = true
+		for _, children := range req.Children {
+			if len(children) > 0 {
+				resp.Finalized = false
+
+				break
+			}
+		}
+
+		return resp, nil
+	})
+}
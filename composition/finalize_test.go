@@ -0,0 +1,71 @@
+package composition
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFinalizeWhenEmpty(t *testing.T) {
+	secrets := corev1.SchemeGroupVersion.WithKind("Secret")
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}
+	parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent"}}
+	status := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "status"}}
+
+	tests := []struct {
+		name          string
+		finalizer     Finalizer[*corev1.ConfigMap]
+		children      []client.Object
+		wantStatus    *corev1.ConfigMap
+		wantFinalized bool
+	}{
+		{name: "no finalizer, no children", wantStatus: parent, wantFinalized: true},
+		{name: "no finalizer, children remain", children: []client.Object{secret}, wantStatus: parent},
+		{
+			name: "finalizer status kept, children discarded",
+			finalizer: FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *FinalizeRequest[*corev1.ConfigMap]) (*FinalizeResponse[*corev1.ConfigMap], error) {
+				return &FinalizeResponse[*corev1.ConfigMap]{
+					Status:   status,
+					Children: map[schema.GroupVersionKind][]client.Object{secrets: {secret}},
+				}, nil
+			}),
+			wantStatus:    status,
+			wantFinalized: true,
+		},
+		{
+			name: "nil response from finalizer",
+			finalizer: FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *FinalizeRequest[*corev1.ConfigMap]) (*FinalizeResponse[*corev1.ConfigMap], error) {
+				return nil, nil
+			}),
+			children:   []client.Object{secret},
+			wantStatus: parent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &FinalizeRequest[*corev1.ConfigMap]{Parent: parent, Children: map[schema.GroupVersionKind][]client.Object{}}
+			if len(tt.children) > 0 {
+				req.Children[secrets] = tt.children
+			}
+
+			resp, err := FinalizeWhenEmpty(tt.finalizer).Finalize(context.Background(), nil, req)
+			if err != nil {
+				t.Fatalf("Finalize() error = %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", resp.Status.Name, tt.wantStatus.Name)
+			}
+			if len(resp.Children) != 0 {
+				t.Errorf("Children = %v, want none", resp.Children)
+			}
+			if resp.Finalized != tt.wantFinalized {
+				t.Errorf("Finalized = %v, want %v", resp.Finalized, tt.wantFinalized)
+			}
+		})
+	}
+}
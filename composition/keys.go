@@ -0,0 +1,33 @@
+package composition
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KeyForGVK returns the key Metacontroller uses for a GroupVersionKind in the
+// children and related maps of a hook request, in the form "Kind.group/version"
+// (or "Kind.version" for the core group), e.g. "Deployment.apps/v1" or "Service.v1".
+func KeyForGVK(gvk schema.GroupVersionKind) string {
+	return gvk.Kind + "." + gvk.GroupVersion().String()
+}
+
+// ParseKey parses a key produced by KeyForGVK back into a GroupVersionKind.
+func ParseKey(key string) (schema.GroupVersionKind, error) {
+	kind, apiVersion, ok := strings.Cut(key, ".")
+	if !ok || kind == "" || apiVersion == "" {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid key %q: expected \"Kind.group/version\" or \"Kind.version\"", key)
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid key %q: %w", key, err)
+	}
+	if gv.Version == "" {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid key %q: missing version", key)
+	}
+
+	return gv.WithKind(kind), nil
+}
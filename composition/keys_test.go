@@ -0,0 +1,51 @@
+package composition
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestKeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		gvk  schema.GroupVersionKind
+		key  string
+	}{
+		{name: "core group", gvk: schema.GroupVersionKind{Version: "v1", Kind: "Service"}, key: "Service.v1"},
+		{name: "named group", gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, key: "Deployment.apps/v1"},
+		{name: "dotted group", gvk: schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}, key: "Certificate.cert-manager.io/v1"},
+		{name: "prerelease version", gvk: schema.GroupVersionKind{Group: "batch", Version: "v2alpha1", Kind: "CronJob"}, key: "CronJob.batch/v2alpha1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyForGVK(tt.gvk); got != tt.key {
+				t.Errorf("KeyForGVK(%v) = %q, want %q", tt.gvk, got, tt.key)
+			}
+			got, err := ParseKey(tt.key)
+			if err != nil {
+				t.Fatalf("ParseKey(%q) error = %v", tt.key, err)
+			}
+			if got != tt.gvk {
+				t.Errorf("ParseKey(%q) = %v, want %v", tt.key, got, tt.gvk)
+			}
+		})
+	}
+}
+
+func TestParseKeyMalformed(t *testing.T) {
+	for _, key := range []string{
+		"",
+		"Service",
+		".v1",
+		"Service.",
+		"Deployment.apps/",
+		"Deployment.apps/v1/extra",
+	} {
+		t.Run(key, func(t *testing.T) {
+			if gvk, err := ParseKey(key); err == nil {
+				t.Errorf("ParseKey(%q) = %v, want an error", key, gvk)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package composition
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// clusterScopedKinds lists built-in cluster-scoped kinds, used to determine the
+// scope of a child when no RESTMapper is available.
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      true,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               true,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       true,
+}
+
+// IsNamespaced reports whether objects of the given kind are namespaced. The
+// scope is looked up in mapper when it is non-nil; otherwise every kind except
+// the built-in cluster-scoped ones is assumed to be namespaced.
+func IsNamespaced(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	if mapper == nil {
+		return !clusterScopedKinds[gvk.GroupKind()], nil
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// DefaultNamespace sets namespace on every namespaced child that has no
+// namespace. Kinds are resolved from the scheme and scopes with IsNamespaced,
+// so cluster-scoped children are left untouched. The children are modified in
+// place, and nil children are skipped; the returned error names each child
+// whose scope could not be determined.
+func DefaultNamespace(scheme *runtime.Scheme, mapper meta.RESTMapper, children []client.Object, namespace string) error {
+	var errs []error
+	for _, child := range children {
+		if isNil(child) || child.GetNamespace() != "" {
+			continue
+		}
+
+		gvk, err := apiutil.GVKForObject(child, scheme)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("child %s: %w", child.GetName(), err))
+
+			continue
+		}
+		namespaced, err := IsNamespaced(mapper, gvk)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("child %s %s: %w", gvk.Kind, child.GetName(), err))
+
+			continue
+		}
+		if namespaced {
+			child.SetNamespace(namespace)
+		}
+	}
+
+	return errors.Join(errs...)
+}
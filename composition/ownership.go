@@ -0,0 +1,23 @@
+package composition
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// SetControllerReference sets owner as the controlling owner reference of child,
+// resolving the owner's GroupVersionKind from the scheme. Metacontroller adds
+// owner references to the children it manages, so this is only needed when
+// children are handed to other controllers or applied outside Metacontroller.
+// It returns an error if the owner's GroupVersionKind cannot be resolved or if
+// child is already controlled by a different owner.
+func SetControllerReference(owner, child client.Object, scheme *runtime.Scheme) error {
+	if err := controllerutil.SetControllerReference(owner, child, scheme); err != nil {
+		return fmt.Errorf("setting controller reference on %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package composition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ParentMetadata describes labels and annotations to set on a parent.
+// Metacontroller only applies a sync response's status, through the parent's
+// status subresource, so metadata changes must be patched onto the parent
+// separately, e.g. with PatchParentMetadata or the ParentPatcher option.
+type ParentMetadata struct {
+	// Labels are merged into the parent's labels.
+	Labels map[string]string
+	// Annotations are merged into the parent's annotations.
+	Annotations map[string]string
+}
+
+// IsEmpty reports whether md sets no labels or annotations.
+func (md *ParentMetadata) IsEmpty() bool {
+	return md == nil || (len(md.Labels) == 0 && len(md.Annotations) == 0)
+}
+
+// PatchParentMetadata merges md into the labels and annotations of parent in
+// the cluster with a JSON merge patch. Existing labels and annotations not named
+// in md are left untouched. It does nothing if md is empty.
+func PatchParentMetadata(ctx context.Context, c client.Client, parent client.Object, md *ParentMetadata) error {
+	if md.IsEmpty() {
+		return nil
+	}
+
+	metadata := map[string]map[string]string{}
+	if len(md.Labels) > 0 {
+		metadata["labels"] = md.Labels
+	}
+	if len(md.Annotations) > 0 {
+		metadata["annotations"] = md.Annotations
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+
+	obj := parent.DeepCopyObject().(client.Object)
+	if err := c.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("error patching metadata of parent %s: %w", objectKey(parent), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package composition
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	api "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ChildPatch is an RFC 6902 JSON Patch to apply to an existing child, returned
+// in a sync response in place of the child's full desired state. Metacontroller
+// itself does not apply child patches: they are encoded under the separate
+// "childPatches" response key and require a patch-aware applier. Stock
+// Metacontroller deletes every observed child missing from Children, so a
+// child returned only as a patch is pruned.
+type ChildPatch struct {
+	// APIVersion is the API version of the child (e.g., "apps/v1").
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the child.
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the child, empty for cluster-scoped children.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the child.
+	Name string `json:"name"`
+	// Patch holds the operations to apply, in order.
+	Patch []PatchOperation `json:"patch"`
+}
+
+// PatchOperation is a single RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	// Op is the operation, e.g. "add", "replace", or "remove".
+	Op string `json:"op"`
+	// Path is the JSON Pointer of the value the operation applies to.
+	Path string `json:"path"`
+	// Value is the value to add or replace with, omitted for operations that
+	// take none.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// NewChildPatch returns a ChildPatch that turns observed into desired. Fields
+// set in desired are added or replaced, recursing into objects and replacing
+// arrays as a whole. Fields that are absent or null in desired are left
+// unchanged, as when Metacontroller applies a full desired child, so
+// server-populated fields such as resourceVersion and status are not removed.
+// The child's apiVersion and kind are resolved from scheme when desired has an
+// empty TypeMeta. The patch requires a patch-aware applier: stock
+// Metacontroller ignores child patches and prunes children returned only as
+// patches.
+func NewChildPatch(scheme *api.Scheme, observed, desired client.Object) (ChildPatch, error) {
+	gvk, err := apiutil.GVKForObject(desired, scheme)
+	if err != nil {
+		return ChildPatch{}, fmt.Errorf("error resolving kind of child %s: %w", objectKey(desired), err)
+	}
+
+	from, err := toJSONValue(observed)
+	if err != nil {
+		return ChildPatch{}, fmt.Errorf("error encoding observed child %s: %w", objectKey(observed), err)
+	}
+	to, err := toJSONValue(desired)
+	if err != nil {
+		return ChildPatch{}, fmt.Errorf("error encoding desired child %s: %w", objectKey(desired), err)
+	}
+	ops, err := diffJSON(nil, "", from, to)
+	if err != nil {
+		return ChildPatch{}, err
+	}
+
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+
+	return ChildPatch{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  desired.GetNamespace(),
+		Name:       desired.GetName(),
+		Patch:      ops,
+	}, nil
+}
+
+// toJSONValue round-trips obj through JSON into maps, slices, and scalars.
+func toJSONValue(obj client.Object) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// diffJSON appends to ops the operations that set the fields of to at path in
+// from, visiting object keys in sorted order so patches are deterministic.
+func diffJSON(ops []PatchOperation, path string, from, to any) ([]PatchOperation, error) {
+	fromMap, fromOK := from.(map[string]any)
+	toMap, toOK := to.(map[string]any)
+	if fromOK && toOK {
+		var err error
+		for _, key := range slices.Sorted(maps.Keys(toMap)) {
+			if toMap[key] == nil {
+				continue
+			}
+			child := path + "/" + escapePointer(key)
+			if fromValue, ok := fromMap[key]; ok {
+				ops, err = diffJSON(ops, child, fromValue, toMap[key])
+			} else {
+				ops, err = appendOperation(ops, "add", child, toMap[key])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return ops, nil
+	}
+	if reflect.DeepEqual(from, to) {
+		return ops, nil
+	}
+
+	return appendOperation(ops, "replace", path, to)
+}
+
+// appendOperation appends an operation setting value at path.
+func appendOperation(ops []PatchOperation, op, path string, value any) ([]PatchOperation, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value at %s: %w", path, err)
+	}
+
+	return append(ops, PatchOperation{Op: op, Path: path, Value: data}), nil
+}
+
+// pointerEscaper escapes JSON Pointer reference tokens as described in RFC 6901.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes a JSON Pointer reference token.
+func escapePointer(token string) string {
+	return pointerEscaper.Replace(token)
+}
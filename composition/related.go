@@ -0,0 +1,194 @@
+package composition
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// RelatedResource returns a ResourceRule selecting obj by name. The apiVersion
+// is resolved from the scheme and the resource is the lowercase plural of the
+// kind, which matches the resource name of built-in and conventionally named
+// custom resources.
+func RelatedResource(scheme *runtime.Scheme, obj client.Object) (ResourceRule, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return ResourceRule{}, fmt.Errorf("error resolving kind of %s: %w", obj.GetName(), err)
+	}
+	if obj.GetName() == "" {
+		return ResourceRule{}, fmt.Errorf("%s has no name", gvk.Kind)
+	}
+
+	rule := RelatedByLabels(gvk, obj.GetNamespace(), nil)
+	rule.Names = []string{obj.GetName()}
+
+	return rule, nil
+}
+
+// RelatedByLabels returns a ResourceRule selecting objects of the given kind in
+// namespace (or in all namespaces when empty) that match selector. A nil
+// selector selects all objects of the kind.
+func RelatedByLabels(gvk schema.GroupVersionKind, namespace string, selector *metav1.LabelSelector) ResourceRule {
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+
+	return ResourceRule{
+		APIVersion:    gvk.GroupVersion().String(),
+		Resource:      gvr.Resource,
+		LabelSelector: selector,
+		Namespace:     namespace,
+	}
+}
+
+// Validate checks that the rule names its apiVersion and resource, that its
+// label selector is valid, and that it does not set both LabelSelector and
+// Names, which select objects in mutually exclusive ways.
+func (r ResourceRule) Validate() error {
+	var errs []error
+	if r.APIVersion == "" {
+		errs = append(errs, errors.New("apiVersion is required"))
+	}
+	if r.Resource == "" {
+		errs = append(errs, errors.New("resource is required"))
+	}
+	if r.LabelSelector != nil {
+		if len(r.Names) > 0 {
+			errs = append(errs, errors.New("labelSelector and names are mutually exclusive"))
+		}
+		if _, err := metav1.LabelSelectorAsSelector(r.LabelSelector); err != nil {
+			errs = append(errs, fmt.Errorf("invalid labelSelector: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("rule for %s %s: %w", r.APIVersion, r.Resource, err)
+	}
+
+	return nil
+}
+
+// ruleKey returns the key under which CustomizeResponseBuilder merges rules.
+func ruleKey(r ResourceRule) string {
+	return r.APIVersion + "|" + r.Resource + "|" + r.Namespace + "|" + metav1.FormatLabelSelector(r.LabelSelector)
+}
+
+// CustomizeResponseBuilder accumulates ResourceRules for a CustomizeResponse.
+// Rules that select the same resource, namespace, and labels are merged: their
+// names are combined, and a rule without names (selecting every matching
+// object) absorbs rules with names. Build sorts the rules and their names, so
+// the response is the same regardless of the order rules were added in.
+type CustomizeResponseBuilder struct {
+	scheme *runtime.Scheme
+	rules  []ResourceRule
+	index  map[string]int
+	err    error
+}
+
+// NewCustomizeResponseBuilder returns a CustomizeResponseBuilder that resolves
+// object kinds with scheme.
+func NewCustomizeResponseBuilder(scheme *runtime.Scheme) *CustomizeResponseBuilder {
+	return &CustomizeResponseBuilder{scheme: scheme, index: make(map[string]int)}
+}
+
+// Add adds rules to the response. The first invalid rule, as reported by
+// ResourceRule.Validate, is returned by Build.
+func (b *CustomizeResponseBuilder) Add(rules ...ResourceRule) *CustomizeResponseBuilder {
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+
+			continue
+		}
+
+		key := ruleKey(rule)
+		i, ok := b.index[key]
+		if !ok {
+			rule.Names = slices.Clone(rule.Names)
+			b.index[key] = len(b.rules)
+			b.rules = append(b.rules, rule)
+
+			continue
+		}
+
+		existing := &b.rules[i]
+		if len(existing.Names) == 0 || len(rule.Names) == 0 {
+			existing.Names = nil
+
+			continue
+		}
+		for _, name := range rule.Names {
+			if !slices.Contains(existing.Names, name) {
+				existing.Names = append(existing.Names, name)
+			}
+		}
+	}
+
+	return b
+}
+
+// AddObjects adds a rule selecting each of objs by name. The first error
+// resolving an object's kind is returned by Build.
+func (b *CustomizeResponseBuilder) AddObjects(objs ...client.Object) *CustomizeResponseBuilder {
+	for _, obj := range objs {
+		rule, err := RelatedResource(b.scheme, obj)
+		if err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+
+			continue
+		}
+		b.Add(rule)
+	}
+
+	return b
+}
+
+// AddInNamespaces adds a copy of rule for each of namespaces, e.g. to select
+// the same kind in several namespaces.
+func (b *CustomizeResponseBuilder) AddInNamespaces(rule ResourceRule, namespaces ...string) *CustomizeResponseBuilder {
+	for _, ns := range namespaces {
+		rule.Namespace = ns
+		b.Add(rule)
+	}
+
+	return b
+}
+
+// AddByLabels adds a rule selecting objects of the given kind by labels.
+func (b *CustomizeResponseBuilder) AddByLabels(gvk schema.GroupVersionKind, namespace string, selector *metav1.LabelSelector) *CustomizeResponseBuilder {
+	return b.Add(RelatedByLabels(gvk, namespace, selector))
+}
+
+// Build returns the accumulated CustomizeResponse, with rules sorted by
+// apiVersion, resource, namespace, and label selector and their names sorted,
+// or the first error recorded while adding rules and objects.
+func (b *CustomizeResponseBuilder) Build() (*CustomizeResponse, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	rules := make([]ResourceRule, len(b.rules))
+	for i, rule := range b.rules {
+		rule.Names = slices.Sorted(slices.Values(rule.Names))
+		rules[i] = rule
+	}
+	slices.SortFunc(rules, func(a, b ResourceRule) int {
+		return cmp.Or(
+			cmp.Compare(a.APIVersion, b.APIVersion),
+			cmp.Compare(a.Resource, b.Resource),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(metav1.FormatLabelSelector(a.LabelSelector), metav1.FormatLabelSelector(b.LabelSelector)),
+		)
+	})
+
+	return &CustomizeResponse{RelatedResources: rules}, nil
+}
@@ -0,0 +1,78 @@
+package composition
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	api "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RetryOptions configures WithRetry.
+type RetryOptions struct {
+	// Attempts is the maximum number of calls, including the first. (Default: 3)
+	Attempts int
+	// InitialBackoff is the delay before the first retry; each later delay is
+	// multiplied by Multiplier. (Default: 100ms)
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. (Default: no cap)
+	MaxBackoff time.Duration
+	// Multiplier scales the delay after each retry. (Default: 2)
+	Multiplier float64
+	// Retryable reports whether an error is transient. (Default: errors
+	// wrapping ErrRetryLater)
+	Retryable func(error) bool
+}
+
+// withDefaults returns o with unset fields defaulted.
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 100 * time.Millisecond
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 2
+	}
+	if o.Retryable == nil {
+		o.Retryable = func(err error) bool { return errors.Is(err, ErrRetryLater) }
+	}
+
+	return o
+}
+
+// WithRetry returns a Syncer that retries syncer with exponential backoff while
+// it returns a retryable error, up to opts.Attempts calls. Waiting stops early
+// when ctx is done, returning the last error; keep the total backoff well within
+// the webhook timeout (see SyncRequest.DeadlineHint). Each attempt receives the
+// same request, so syncer must not rely on modifying it.
+func WithRetry[P client.Object](syncer Syncer[P], opts RetryOptions) Syncer[P] {
+	opts = opts.withDefaults()
+
+	return SyncerFunc[P](func(ctx context.Context, scheme *api.Scheme, req *SyncRequest[P]) (*SyncResponse[P], error) {
+		backoff := opts.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			resp, err := syncer.Sync(ctx, scheme, req)
+			if err == nil || attempt >= opts.Attempts || !opts.Retryable(err) {
+				return resp, err
+			}
+			LoggerFromContext(ctx).DebugContext(ctx, "Retrying sync", "attempt", attempt, "backoff", backoff, "error", err)
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return nil, err
+			case <-timer.C:
+			}
+
+			backoff = time.Duration(float64(backoff) * opts.Multiplier)
+			if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+				backoff = opts.MaxBackoff
+			}
+		}
+	})
+}
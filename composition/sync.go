@@ -2,27 +2,141 @@ package composition
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	api "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // SyncRequest represents the fully decoded sync hook request.
 type SyncRequest[P client.Object] struct {
+	// Controller is the CompositeController that invoked the hook, as raw JSON.
+	// DecodeController decodes it into a CompositeController.
+	Controller json.RawMessage
 	// Parent is the composite (parent) resource.
 	Parent P
-	// Children is a map from GroupVersionKind to slices of decoded child objects.
+	// Children is a map from GroupVersionKind to slices of decoded child
+	// objects. Each slice is sorted by namespace, then by name.
 	Children map[schema.GroupVersionKind][]client.Object
+	// Related is a map from GroupVersionKind to slices of decoded related
+	// objects, as selected by the customize hook.
+	Related map[schema.GroupVersionKind][]client.Object
+	// Finalizing indicates that the parent is being deleted and Metacontroller
+	// is calling the sync hook in place of a finalize hook. The Syncer should
+	// then behave like a Finalizer: clean up, return the children that must
+	// remain, and set SyncResponse.Finalized once nothing is left to do, so the
+	// parent's finalizer is removed.
+	Finalizing bool
+	// DeletionTimestamp is the parent's deletion timestamp, or nil if it has
+	// not been deleted.
+	DeletionTimestamp *metav1.Time
+}
+
+// IsBeingDeleted reports whether the parent is being deleted, either because
+// Metacontroller is finalizing it or because it has a deletion timestamp.
+func (r *SyncRequest[P]) IsBeingDeleted() bool {
+	return r.Finalizing || r.DeletionTimestamp != nil
 }
 
 // SyncResponse represents the sync hook response.
 type SyncResponse[P client.Object] struct {
-	// Status is the updated composite (parent) resource.
+	// Status is the updated composite (parent) resource. Metacontroller only
+	// applies its status, through the status subresource; changes to its
+	// metadata or spec are ignored. Use ParentMetadata to change labels and
+	// annotations. A nil Status omits the status from the response, leaving
+	// the parent's status untouched; a parent with an empty status clears it.
 	Status P
 	// Children defines the desired state for child objects.
 	Children []client.Object
+	// ChildPatches holds JSON Patches for existing children that are not
+	// returned in full in Children, e.g. very large ones; NewChildPatch computes
+	// them. They are encoded under a separate response key that Metacontroller
+	// ignores, so they require a patch-aware applier: stock Metacontroller
+	// prunes children returned only as patches.
+	ChildPatches []ChildPatch
+	// ParentMetadata, if set, holds labels and annotations to patch onto the
+	// parent. It is not part of Metacontroller's response; the HookServer
+	// applies it only when configured with the ParentPatcher option.
+	ParentMetadata *ParentMetadata
+	// Finalized indicates, when the request is Finalizing, that cleanup is
+	// complete and the parent's finalizer can be removed.
+	Finalized bool
+	// ResyncAfter, if positive, asks Metacontroller to sync the parent again
+	// after the given duration, in addition to its regular resyncs.
+	ResyncAfter time.Duration
+	// Prune marks the kinds whose observed children are meant to be deleted
+	// by returning none of them, matched by group and kind. The HookServer
+	// warns about, or with RequireExplicitPrune rejects, responses that
+	// return no children of an observed kind that is not marked, since
+	// Metacontroller deletes them all.
+	Prune []schema.GroupVersionKind
+}
+
+// Validate checks that the response can be encoded: every child must be
+// non-nil, have a name, and have a GroupVersionKind that resolves from the
+// scheme, and every child patch must identify its child. The returned error
+// describes every problem found. Status is not checked: a nil Status is valid
+// and leaves the parent's status untouched.
+func (r *SyncResponse[P]) Validate(scheme *api.Scheme) error {
+	var errs []error
+	for i, child := range r.Children {
+		errs = append(errs, validateChild(scheme, fmt.Sprintf("child %d", i), child)...)
+	}
+	for i, patch := range r.ChildPatches {
+		if patch.APIVersion == "" || patch.Kind == "" || patch.Name == "" {
+			errs = append(errs, fmt.Errorf("child patch %d has no apiVersion, kind, or name", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateChild returns the problems that keep child, described by name in
+// errors, from being encoded.
+func validateChild(scheme *api.Scheme, name string, child client.Object) []error {
+	if isNil(child) {
+		return []error{fmt.Errorf("%s is nil", name)}
+	}
+
+	var errs []error
+	if child.GetName() == "" {
+		errs = append(errs, fmt.Errorf("%s has no name", name))
+	}
+	if _, err := apiutil.GVKForObject(child, scheme); err != nil {
+		errs = append(errs, fmt.Errorf("%s (%s): %w", name, objectKey(child), err))
+	}
+
+	return errs
+}
+
+// AddChild appends obj to the desired children. It returns an error, leaving
+// Children unchanged, if obj's GroupVersionKind cannot be resolved from scheme,
+// since such a child could not be encoded.
+func (r *SyncResponse[P]) AddChild(scheme *api.Scheme, obj client.Object) error {
+	if _, err := apiutil.GVKForObject(obj, scheme); err != nil {
+		return fmt.Errorf("error resolving kind of child %s: %w", objectKey(obj), err)
+	}
+	r.Children = append(r.Children, obj)
+
+	return nil
+}
+
+// isNil reports whether obj is nil or a nil pointer.
+func isNil(obj client.Object) bool {
+	if obj == nil {
+		return true
+	}
+	v := reflect.ValueOf(obj)
+
+	return v.Kind() == reflect.Pointer && v.IsNil()
 }
 
 // Syncer is an interface for processing sync hook requests.
@@ -30,6 +144,8 @@ type Syncer[P client.Object] interface {
 	// Sync is a function that processes sync requests.
 	// It receives a context, the runtime scheme, and a decoded sync request,
 	// then returns a sync response or an error.
+	// The context is canceled when Metacontroller abandons the request (e.g. after
+	// its webhook timeout), so long-running work should honor ctx.Done().
 	Sync(
 		ctx context.Context,
 		scheme *api.Scheme,
@@ -0,0 +1,65 @@
+package composition
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// widget is a child type that is not registered in any scheme.
+type widget struct{ corev1.Secret }
+
+func TestSyncResponseValidate(t *testing.T) {
+	named := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}
+
+	tests := []struct {
+		name string
+		resp *SyncResponse[*corev1.ConfigMap]
+		want []string
+	}{
+		{name: "nil status", resp: &SyncResponse[*corev1.ConfigMap]{Children: []client.Object{named}}},
+		{name: "nil child", resp: &SyncResponse[*corev1.ConfigMap]{Children: []client.Object{named, (*corev1.Secret)(nil)}}, want: []string{"child 1 is nil"}},
+		{name: "unnamed child", resp: &SyncResponse[*corev1.ConfigMap]{Children: []client.Object{&corev1.Secret{}}}, want: []string{"child 0 has no name"}},
+		{name: "unregistered child", resp: &SyncResponse[*corev1.ConfigMap]{Children: []client.Object{&widget{Secret: *named}}}, want: []string{"child 0 (s)"}},
+		{name: "child patch without kind", resp: &SyncResponse[*corev1.ConfigMap]{ChildPatches: []ChildPatch{{Name: "s"}}}, want: []string{"child patch 0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertErrors(t, tt.resp.Validate(testScheme(t)), tt.want)
+		})
+	}
+}
+
+func TestFinalizeResponseValidate(t *testing.T) {
+	secrets := corev1.SchemeGroupVersion.WithKind("Secret")
+	resp := &FinalizeResponse[*corev1.ConfigMap]{Children: map[schema.GroupVersionKind][]client.Object{
+		secrets: {&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}, nil, &corev1.Secret{}},
+	}}
+
+	assertErrors(t, resp.Validate(testScheme(t)), []string{"child Secret.v1 1 is nil", "child Secret.v1 2 has no name"})
+	assertErrors(t, (&FinalizeResponse[*corev1.ConfigMap]{}).Validate(testScheme(t)), nil)
+}
+
+// assertErrors checks that err mentions each of want, or is nil if want is empty.
+func assertErrors(t *testing.T, err error, want []string) {
+	t.Helper()
+	if len(want) == 0 {
+		if err != nil {
+			t.Errorf("Validate() error = %v, want nil", err)
+		}
+
+		return
+	}
+	if err == nil {
+		t.Fatalf("Validate() error = nil, want %q", want)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("Validate() error = %v, want it to mention %q", err, w)
+		}
+	}
+}
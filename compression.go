@@ -0,0 +1,140 @@
+package metacontroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionMinBytes is the default size below which responses are not
+// compressed.
+const DefaultCompressionMinBytes = 1 << 10
+
+// Compression creates an option that negotiates gzip compression on hook
+// requests and responses. Request bodies sent with "Content-Encoding: gzip" are
+// decompressed before decoding, with MaxRequestBytes applied to the
+// decompressed size as well, and responses of at least minBytes are compressed
+// for clients that send "Accept-Encoding: gzip". A minBytes of zero or less uses
+// DefaultCompressionMinBytes. Without this option, compressed requests are
+// rejected with 415 Unsupported Media Type.
+func Compression(minBytes int) Option {
+	return func(hs *HookServer) {
+		if minBytes <= 0 {
+			minBytes = DefaultCompressionMinBytes
+		}
+		hs.compressionMinBytes = minBytes
+	}
+}
+
+// compressionMiddleware decompresses gzipped request bodies and compresses
+// responses of at least minBytes for clients that accept gzip. The decompressed
+// body is limited to maxBytes when positive.
+func compressionMiddleware(minBytes int, maxBytes int64, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("error decompressing request: %w", err), logger)
+
+				return
+			}
+			defer zr.Close()
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{zr, r.Body}
+			if maxBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			r.Header.Del("Content-Encoding")
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(gw, r)
+		if err := gw.finish(minBytes); err != nil {
+			logger.ErrorContext(r.Context(), "Error writing compressed response: "+err.Error())
+		}
+	})
+}
+
+// checkContentEncoding rejects requests with a Content-Encoding other than
+// identity, unless compression is enabled and the encoding is gzip.
+func checkContentEncoding(r *http.Request, compression bool) error {
+	enc := r.Header.Get("Content-Encoding")
+	if enc == "" || strings.EqualFold(enc, "identity") || (compression && strings.EqualFold(enc, "gzip")) {
+		return nil
+	}
+
+	return fmt.Errorf("unsupported Content-Encoding %q", enc)
+}
+
+// acceptsGzip reports whether an Accept-Encoding header value accepts gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimSpace(params), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			return true
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		return err == nil && q > 0
+	}
+
+	return false
+}
+
+// gzipResponseWriter buffers a response so it can be compressed once its size
+// is known. Hook responses are already buffered in full before being written,
+// so this does not add latency.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+// WriteHeader records the status code until the response is finished.
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// Write buffers b until the response is finished.
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+// finish writes the buffered response, compressed if it is at least minBytes
+// and not already encoded.
+func (w *gzipResponseWriter) finish(minBytes int) error {
+	h := w.Header()
+	if w.buf.Len() < minBytes || h.Get("Content-Encoding") != "" {
+		w.ResponseWriter.WriteHeader(w.status)
+		_, err := w.buf.WriteTo(w.ResponseWriter)
+
+		return err
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	zw := gzip.NewWriter(w.ResponseWriter)
+	if _, err := w.buf.WriteTo(zw); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,78 @@
+package metacontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// InFlightRecorder is optionally implemented by a MetricsRecorder to observe
+// the number of hook requests in flight against a concurrency limit, e.g. as a
+// gauge. It is only called for routes with a limit set by MaxConcurrentRequests
+// or WithMaxConcurrentRequests.
+type InFlightRecorder interface {
+	// ObserveInFlight records that n requests are in flight against the limit
+	// that applies to the hook identified by hookType and resource.
+	ObserveInFlight(ctx context.Context, hookType, resource string, n int)
+}
+
+// MaxConcurrentRequests limits the number of hook requests handled at once
+// across all hooks to n. Requests beyond the limit are rejected immediately
+// with 429 Too Many Requests so Metacontroller retries them later. A value of
+// zero or less disables the limit. (Default: no limit)
+func MaxConcurrentRequests(n int) Option {
+	return func(hs *HookServer) {
+		hs.concurrency = newLimiter(n)
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of requests handled at once by a
+// single hook to n, in addition to any server-wide MaxConcurrentRequests limit.
+func WithMaxConcurrentRequests(n int) HookOption {
+	return func(cfg *hookConfig) {
+		cfg.maxConcurrent = n
+	}
+}
+
+// limiter is a counting semaphore bounding concurrent requests.
+type limiter chan struct{}
+
+// newLimiter returns a limiter admitting n concurrent requests, or nil if n is
+// zero or less.
+func newLimiter(n int) limiter {
+	if n <= 0 {
+		return nil
+	}
+
+	return make(limiter, n)
+}
+
+// concurrencyMiddleware rejects requests to a hook route with 429 Too Many
+// Requests while lim is full.
+func (hs *HookServer) concurrencyMiddleware(lim limiter, rt hookRoute, next http.Handler) http.Handler {
+	resource := rt.resource()
+	inFlight, _ := hs.metrics.(InFlightRecorder)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case lim <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeError(r.Context(), w, http.StatusTooManyRequests,
+				fmt.Errorf("%s hook for %s exceeded %d concurrent requests", rt.hookType, resource, cap(lim)),
+				hs.logger)
+
+			return
+		}
+		if inFlight != nil {
+			inFlight.ObserveInFlight(r.Context(), rt.hookType, resource, len(lim))
+		}
+		defer func() {
+			<-lim
+			if inFlight != nil {
+				inFlight.ObserveInFlight(r.Context(), rt.hookType, resource, len(lim))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,145 @@
+package metacontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// ConvertingSyncHook registers a sync hook for the parent resource identified by
+// gvr that accepts parents in any of the resource's versions registered in the
+// scheme. Each parent is converted to the hub version with the scheme's
+// conversion functions before syncer runs, and the returned status is converted
+// back to the version the parent was sent in. P is the hub version's type, and
+// the hook is served at the path of gvr's version.
+func ConvertingSyncHook[P client.Object](gvr schema.GroupVersionResource, hub schema.GroupVersion, syncer composition.Syncer[P], opts ...HookOption) CompositeHook {
+	return CompositeHook(func(hs *HookServer) {
+		cfg := newHookConfig(opts)
+		hs.handleHook(HookTypeSync, gvr, cfg, &convertingSyncHandler[P]{
+			sh:     newSyncHandler(hs, gvr, cfg, syncer),
+			hub:    hub,
+			logger: hs.logger,
+		})
+	})
+}
+
+// convertingSyncHandler handles sync hook requests for parents of any version,
+// converting them to and from the hub version around a syncHandler.
+type convertingSyncHandler[P client.Object] struct {
+	sh     *syncHandler[P]
+	hub    schema.GroupVersion
+	logger *slog.Logger
+}
+
+// decodeRequest implements requestDecoder. The parent is decoded in the version
+// it was sent in and converted to the hub version.
+func (ch *convertingSyncHandler[P]) decodeRequest(r *http.Request) *hookRequest {
+	req := &hookRequest{}
+	if code, err := decodeBody(r, &req.raw); err != nil {
+		req.code, req.err = code, fmt.Errorf("SyncHook: error decoding request: %w", err)
+
+		return req
+	}
+
+	obj, gvk, err := ch.sh.decoder.Decode(req.raw.Parent, nil, nil)
+	if err != nil {
+		req.code, req.err = decodeErrorStatus(err), fmt.Errorf("SyncHook: error decoding parent: %w", err)
+
+		return req
+	}
+	parent, ok := obj.(client.Object)
+	if !ok {
+		req.code, req.err = http.StatusBadRequest, fmt.Errorf("SyncHook: parent %s is %T, not a client.Object", gvk, obj)
+
+		return req
+	}
+	matcher := ch.sh.parentMatcher
+	matcher.gvr.Version = gvk.Version
+	if err := matcher.check(ch.sh.scheme, parent); err != nil {
+		req.code, req.err = http.StatusBadRequest, fmt.Errorf("SyncHook: unexpected parent: %w", err)
+
+		return req
+	}
+
+	if gvk.GroupVersion() != ch.hub {
+		if obj, err = ch.sh.scheme.ConvertToVersion(obj, ch.hub); err != nil {
+			req.code, req.err = http.StatusInternalServerError, fmt.Errorf("SyncHook: error converting parent %s to %s: %w", gvk, ch.hub, err)
+
+			return req
+		}
+	}
+	hubParent, ok := obj.(P)
+	if !ok {
+		var zero P
+		req.code, req.err = http.StatusInternalServerError, fmt.Errorf("SyncHook: converted parent is %T, not %T", obj, zero)
+
+		return req
+	}
+	req.parent = hubParent
+
+	return req
+}
+
+// checkParentType implements parentTypeChecker. P must be registered in the
+// scheme in the hub version.
+func (ch *convertingSyncHandler[P]) checkParentType() error {
+	matcher := ch.sh.parentMatcher
+	matcher.gvr.Version = ch.hub.Version
+	if err := checkParentType[P](ch.sh.scheme, matcher); err != nil {
+		return err
+	}
+
+	var zero P
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return nil
+	}
+	parent := reflect.New(typ.Elem()).Interface().(client.Object)
+	if _, ok := parent.(runtime.Unstructured); ok {
+		return nil
+	}
+	if gvk, err := apiutil.GVKForObject(parent, ch.sh.scheme); err == nil && gvk.GroupVersion() != ch.hub {
+		return fmt.Errorf("parent type %T is registered as %s, not in hub version %s", zero, gvk, ch.hub)
+	}
+
+	return nil
+}
+
+// parentType implements parentTyper. The hub version's type is reported.
+func (ch *convertingSyncHandler[P]) parentType() (schema.GroupVersionKind, reflect.Type, bool) {
+	return ch.sh.parentType()
+}
+
+// ServeHTTP processes sync hook HTTP requests, encoding the status in the
+// version the parent was sent in.
+func (ch *convertingSyncHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := requestFrom(r, ch)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, ch.logger)
+
+		return
+	}
+
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(req.raw.Parent, &typeMeta); err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("SyncHook: error decoding parent: %w", err), ch.logger)
+
+		return
+	}
+	var statusVersion schema.GroupVersion
+	if gv := typeMeta.GroupVersionKind().GroupVersion(); gv != ch.hub {
+		statusVersion = gv
+	}
+
+	ch.sh.serve(w, r, &req.raw, req.parent.(P), statusVersion)
+}
@@ -0,0 +1,349 @@
+package metacontroller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// validateChildKinds checks that every desired child has a GroupVersionKind the
+// encoder can emit: typed children must be registered in the scheme, and
+// unstructured children must carry apiVersion and kind. The returned error names
+// each offending child.
+func validateChildKinds(scheme *runtime.Scheme, children []client.Object) error {
+	var errs []error
+	for i, child := range children {
+		if isNilObject(child) {
+			errs = append(errs, fmt.Errorf("child %d is nil", i))
+
+			continue
+		}
+
+		gvk := child.GetObjectKind().GroupVersionKind()
+		if _, ok := child.(runtime.Unstructured); ok {
+			if gvk.Kind == "" || gvk.Version == "" {
+				errs = append(errs, fmt.Errorf("unstructured child %s/%s is missing apiVersion/kind", child.GetNamespace(), child.GetName()))
+			}
+
+			continue
+		}
+
+		if _, _, err := scheme.ObjectKinds(child); err != nil {
+			errs = append(errs, fmt.Errorf("child %s/%s of type %T is not registered in the scheme", child.GetNamespace(), child.GetName(), child))
+
+			continue
+		}
+		if !gvk.Empty() && !scheme.Recognizes(gvk) {
+			errs = append(errs, fmt.Errorf("child %s/%s has kind %s, which is not registered in the scheme", child.GetNamespace(), child.GetName(), gvk))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DedupeChildren creates an option that collapses desired children of sync and
+// finalize responses sharing the same GroupVersionKind, namespace, and name
+// before they are returned to Metacontroller, which otherwise rejects the
+// response. Identical duplicates are dropped with a warning, keeping the last
+// occurrence; duplicates that differ are a conflict and fail the hook with 500
+// Internal Server Error.
+func DedupeChildren() Option {
+	return func(hs *HookServer) {
+		hs.dedupeChildren = true
+	}
+}
+
+// childKey identifies a child by GroupVersionKind, namespace, and name.
+type childKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// newChildKey returns the childKey of obj, resolving its GroupVersionKind from the scheme.
+func newChildKey(scheme *runtime.Scheme, obj client.Object) (childKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return childKey{}, err
+	}
+
+	return childKey{gvk: gvk, namespace: obj.GetNamespace(), name: obj.GetName()}, nil
+}
+
+// String returns the key in "Kind.group/version namespace/name" form.
+func (k childKey) String() string {
+	if k.namespace == "" {
+		return composition.KeyForGVK(k.gvk) + " " + k.name
+	}
+
+	return composition.KeyForGVK(k.gvk) + " " + k.namespace + "/" + k.name
+}
+
+// dedupeChildren collapses children that share a childKey. Each child keeps the
+// position of its first occurrence and the value of its last one. It returns an
+// error if two children share a key but are not semantically equal.
+func dedupeChildren(ctx context.Context, scheme *runtime.Scheme, children []client.Object, logger *slog.Logger) ([]client.Object, error) {
+	deduped := make([]client.Object, 0, len(children))
+	seen := make(map[childKey]int, len(children))
+	for _, child := range children {
+		key, err := newChildKey(scheme, child)
+		if err != nil {
+			return nil, fmt.Errorf("error identifying child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+		}
+
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(deduped)
+			deduped = append(deduped, child)
+
+			continue
+		}
+		if !equality.Semantic.DeepEqual(deduped[i], child) {
+			return nil, fmt.Errorf("conflicting desired children for %s", key)
+		}
+		logger.WarnContext(ctx, "Dropping duplicate desired child", "child", key.String())
+		deduped[i] = child
+	}
+
+	return deduped, nil
+}
+
+// DefaultChildNamespace creates an option that sets the namespace of every
+// namespaced desired child without one once a sync or finalize hook returns,
+// before ChildMutators and AllowCrossNamespaceChildren see it. Children are
+// placed in the parent's namespace, or in ns when the parent is cluster-scoped.
+// Cluster-scoped kinds are recognized with the RESTMapper option when set, or
+// from a list of built-in kinds otherwise; see composition.DefaultNamespace to
+// apply the same defaulting within a hook.
+func DefaultChildNamespace(ns string) Option {
+	return func(hs *HookServer) {
+		hs.childNamespaces = &namespaceDefaulter{fallback: ns}
+	}
+}
+
+// AllowCrossNamespaceChildren creates an option that controls whether sync and
+// finalize hooks may return children in a namespace other than their parent's.
+// When disallowed, such a response fails the hook with 500 Internal Server
+// Error naming the offending children, which guards against accidental writes
+// to other namespaces. Children without a namespace and children of
+// cluster-scoped parents are not checked. (Default: false)
+func AllowCrossNamespaceChildren(allow bool) Option {
+	return func(hs *HookServer) {
+		hs.crossNamespace = allow
+	}
+}
+
+// checkChildNamespaces returns an error naming each child in a namespace other
+// than parent's. Children without a namespace and children of cluster-scoped
+// parents are not checked, nor are nil children, which validateChildKinds
+// reports.
+func checkChildNamespaces(parent client.Object, children []client.Object) error {
+	ns := parent.GetNamespace()
+	if ns == "" {
+		return nil
+	}
+
+	var errs []error
+	for _, child := range children {
+		if isNilObject(child) {
+			continue
+		}
+		if child.GetNamespace() != "" && child.GetNamespace() != ns {
+			errs = append(errs, fmt.Errorf("child %s/%s is not in the parent's namespace %s", child.GetNamespace(), child.GetName(), ns))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RequireExplicitPrune creates an option that fails sync hooks with 500
+// Internal Server Error when they return no children of a kind that has
+// observed children, unless the kind is listed in SyncResponse.Prune. This
+// guards against a bug in a Syncer deleting every child of a kind. Without it
+// such responses are only logged as a warning. Syncs of a parent that is being
+// finalized are not checked.
+func RequireExplicitPrune() Option {
+	return func(hs *HookServer) {
+		hs.explicitPrune = true
+	}
+}
+
+// unprunedKinds returns the kinds of observed children, sorted by key, that
+// children omit entirely and that are not listed in prune. Child patches do not
+// keep a kind: Metacontroller ignores them and deletes every observed child
+// missing from children.
+func unprunedKinds(scheme *runtime.Scheme, observed map[schema.GroupVersionKind][]client.Object, children []client.Object, prune []schema.GroupVersionKind) []schema.GroupVersionKind {
+	kept := make(map[schema.GroupKind]bool, len(prune))
+	for _, gvk := range prune {
+		kept[gvk.GroupKind()] = true
+	}
+	for _, child := range children {
+		if gvk, err := apiutil.GVKForObject(child, scheme); err == nil {
+			kept[gvk.GroupKind()] = true
+		}
+	}
+
+	var kinds []schema.GroupVersionKind
+	for gvk, objs := range observed {
+		if len(objs) > 0 && !kept[gvk.GroupKind()] {
+			kinds = append(kinds, gvk)
+		}
+	}
+	slices.SortFunc(kinds, func(a, b schema.GroupVersionKind) int {
+		return cmp.Compare(composition.KeyForGVK(a), composition.KeyForGVK(b))
+	})
+
+	return kinds
+}
+
+// ChildMutator creates an option that calls fn with each desired child of sync
+// and finalize responses before they are encoded, e.g. to inject sidecars or
+// add labels uniformly instead of in every Syncer. Children have their
+// namespaces defaulted first, and the checks of other options apply to the
+// mutated children. Mutators run in the order they were registered; an error
+// fails the hook with 500 Internal Server Error.
+func ChildMutator(fn func(ctx context.Context, parent, child client.Object) error) Option {
+	return func(hs *HookServer) {
+		hs.childMutators = append(hs.childMutators, fn)
+	}
+}
+
+// mutateChildren calls each mutator with every non-nil child in turn.
+func mutateChildren(ctx context.Context, mutators []func(context.Context, client.Object, client.Object) error, parent client.Object, children []client.Object) error {
+	for _, child := range children {
+		if isNilObject(child) {
+			continue
+		}
+		for _, mutate := range mutators {
+			if err := mutate(ctx, parent, child); err != nil {
+				return fmt.Errorf("error mutating child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RESTMapper creates an option that sets the RESTMapper used to determine
+// whether child kinds are namespaced or cluster-scoped, e.g. one created with
+// apiutil.NewDynamicRESTMapper. It is required to recognize cluster-scoped
+// custom resources.
+func RESTMapper(mapper meta.RESTMapper) Option {
+	return func(hs *HookServer) {
+		hs.restMapper = mapper
+	}
+}
+
+// namespaceDefaulter defaults the namespace of desired children.
+type namespaceDefaulter struct {
+	fallback string
+	mapper   meta.RESTMapper
+}
+
+// withMapper returns a copy of d that determines scopes with mapper, or nil if d is nil.
+func (d *namespaceDefaulter) withMapper(mapper meta.RESTMapper) *namespaceDefaulter {
+	if d == nil {
+		return nil
+	}
+
+	return &namespaceDefaulter{fallback: d.fallback, mapper: mapper}
+}
+
+// namespace returns the namespace of children of parent: the parent's
+// namespace, or the fallback namespace for cluster-scoped parents.
+func (d *namespaceDefaulter) namespace(parent client.Object) string {
+	if ns := parent.GetNamespace(); ns != "" {
+		return ns
+	}
+
+	return d.fallback
+}
+
+// apply sets the namespace of namespaced children without one to the
+// namespace returned by d.namespace.
+func (d *namespaceDefaulter) apply(scheme *runtime.Scheme, parent client.Object, children []client.Object) error {
+	return composition.DefaultNamespace(scheme, d.mapper, children, d.namespace(parent))
+}
+
+// SummaryLogs creates an option that logs a one-line summary of every
+// successful sync at info level, with the number of observed and desired
+// children per kind. Without it the summary is logged at debug level.
+func SummaryLogs() Option {
+	return func(hs *HookServer) {
+		hs.summaryLogs = true
+	}
+}
+
+// countObserved returns the number of observed children per kind, keyed as in
+// Metacontroller's children map.
+func countObserved(keys childKeys, children map[schema.GroupVersionKind][]client.Object) map[string]int {
+	counts := make(map[string]int, len(children))
+	for gvk, objs := range children {
+		counts[keys.key(gvk)] += len(objs)
+	}
+
+	return counts
+}
+
+// flattenChildren returns the children of a map grouped by GroupVersionKind as
+// a single slice, ordered by kind and then as given.
+func flattenChildren(children map[schema.GroupVersionKind][]client.Object) []client.Object {
+	gvks := slices.SortedFunc(maps.Keys(children), func(a, b schema.GroupVersionKind) int {
+		return cmp.Compare(composition.KeyForGVK(a), composition.KeyForGVK(b))
+	})
+	var flat []client.Object
+	for _, gvk := range gvks {
+		flat = append(flat, children[gvk]...)
+	}
+
+	return flat
+}
+
+// countDesired returns the number of desired children per kind, keyed as in
+// Metacontroller's children map.
+func countDesired(scheme *runtime.Scheme, keys childKeys, children []client.Object) map[string]int {
+	counts := make(map[string]int)
+	for _, child := range children {
+		gvk, err := apiutil.GVKForObject(child, scheme)
+		if err != nil {
+			continue
+		}
+		counts[keys.key(gvk)]++
+	}
+
+	return counts
+}
+
+// ParentPatcher creates an option that applies the ParentMetadata of each sync
+// response to the parent in the cluster with c before responding. A failed patch
+// fails the hook with 500 Internal Server Error so Metacontroller retries it.
+// Without this option ParentMetadata is ignored, since Metacontroller itself
+// only applies the parent's status.
+func ParentPatcher(c client.Client) Option {
+	return func(hs *HookServer) {
+		hs.parentPatcher = c
+	}
+}
+
+// ValidateResponses creates an option that checks every sync, finalize, and
+// customize response with SyncResponse.Validate, FinalizeResponse.Validate,
+// and CustomizeResponse.Validate, and fails the hook with 500 Internal Server
+// Error, naming each problem, when it is invalid.
+func ValidateResponses() Option {
+	return func(hs *HookServer) {
+		hs.validateResponses = true
+	}
+}
@@ -0,0 +1,236 @@
+package metacontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+func TestUnprunedKinds(t *testing.T) {
+	secrets := corev1.SchemeGroupVersion.WithKind("Secret")
+	services := corev1.SchemeGroupVersion.WithKind("Service")
+	observed := map[schema.GroupVersionKind][]client.Object{
+		secrets:  {&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}},
+		services: {&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc"}}},
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}
+
+	tests := []struct {
+		name     string
+		children []client.Object
+		prune    []schema.GroupVersionKind
+		want     []schema.GroupVersionKind
+	}{
+		{name: "all kinds omitted", want: []schema.GroupVersionKind{secrets, services}},
+		{name: "one kind kept", children: []client.Object{secret}, want: []schema.GroupVersionKind{services}},
+		{name: "omitted kind pruned", children: []client.Object{secret}, prune: []schema.GroupVersionKind{services}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unprunedKinds(testScheme(t), observed, tt.children, tt.prune)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unprunedKinds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPruneIgnoresChildPatches(t *testing.T) {
+	scheme := testScheme(t)
+	hs := newSyncServer(t, syncFunc(func(_ context.Context, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		observed := req.Children[corev1.SchemeGroupVersion.WithKind("Secret")][0]
+		desired := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "default"},
+			StringData: map[string]string{"k": "v"},
+		}
+		patch, err := composition.NewChildPatch(scheme, observed, desired)
+		if err != nil {
+			return nil, err
+		}
+
+		return &composition.SyncResponse[*corev1.ConfigMap]{ChildPatches: []composition.ChildPatch{patch}}, nil
+	}), RequireExplicitPrune())
+
+	body := `{"parent":` + parentJSON + `,"children":{"Secret.v1":{"default/s":{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s","namespace":"default"}}}}}`
+	if w := postSync(hs, body, nil); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d: a kind returned only as patches is pruned by Metacontroller", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestNilDesiredChild(t *testing.T) {
+	children := []client.Object{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}}, nil, (*corev1.Secret)(nil)}
+	syncer := syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return &composition.SyncResponse[*corev1.ConfigMap]{Children: children}, nil
+	})
+	finalizer := composition.FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *composition.FinalizeRequest[*corev1.ConfigMap]) (*composition.FinalizeResponse[*corev1.ConfigMap], error) {
+		return &composition.FinalizeResponse[*corev1.ConfigMap]{
+			Children: map[schema.GroupVersionKind][]client.Object{corev1.SchemeGroupVersion.WithKind("Secret"): children},
+		}, nil
+	})
+
+	for _, hookType := range []string{HookTypeSync, HookTypeFinalize} {
+		t.Run(hookType, func(t *testing.T) {
+			// Error details are only included in responses at debug level.
+			var logs bytes.Buffer
+			hs := NewHookServer(testScheme(t),
+				Logger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+				CompositeController(SyncHook(configMaps, syncer), FinalizeHook(configMaps, finalizer)))
+
+			// parentJSON is namespaced, so the namespace check applies.
+			r := httptest.NewRequest(http.MethodPost, hs.HookPath(hookType, configMaps), strings.NewReader(`{"parent":`+parentJSON+`}`))
+			w := httptest.NewRecorder()
+			hs.ServeHTTP(w, r)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			for _, want := range []string{"child 1 is nil", "child 2 is nil"} {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("response %q does not contain %q", w.Body, want)
+				}
+			}
+			if strings.Contains(logs.String(), "panic") {
+				t.Errorf("hook panicked: %s", logs.String())
+			}
+		})
+	}
+}
+
+func TestValidateResponsesFinalize(t *testing.T) {
+	finalizer := composition.FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *composition.FinalizeRequest[*corev1.ConfigMap]) (*composition.FinalizeResponse[*corev1.ConfigMap], error) {
+		return &composition.FinalizeResponse[*corev1.ConfigMap]{
+			Children: map[schema.GroupVersionKind][]client.Object{corev1.SchemeGroupVersion.WithKind("Secret"): {&corev1.Secret{}}},
+		}, nil
+	})
+
+	for _, validate := range []bool{false, true} {
+		opts := []Option{discardLogger(), CompositeController(FinalizeHook(configMaps, finalizer))}
+		want := http.StatusOK
+		if validate {
+			opts = append(opts, ValidateResponses())
+			want = http.StatusInternalServerError
+		}
+		hs := NewHookServer(testScheme(t), opts...)
+
+		r := httptest.NewRequest(http.MethodPost, hs.HookPath(HookTypeFinalize, configMaps), strings.NewReader(`{"parent":`+parentJSON+`}`))
+		w := httptest.NewRecorder()
+		hs.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("ValidateResponses %v: status = %d, want %d for an unnamed child", validate, w.Code, want)
+		}
+	}
+}
+
+func TestDefaultChildNamespaceFinalize(t *testing.T) {
+	children := func() []client.Object {
+		return []client.Object{
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+		}
+	}
+	syncer := syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return &composition.SyncResponse[*corev1.ConfigMap]{Children: children()}, nil
+	})
+	finalizer := composition.FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *composition.FinalizeRequest[*corev1.ConfigMap]) (*composition.FinalizeResponse[*corev1.ConfigMap], error) {
+		objs := children()
+
+		return &composition.FinalizeResponse[*corev1.ConfigMap]{Children: map[schema.GroupVersionKind][]client.Object{
+			corev1.SchemeGroupVersion.WithKind("Secret"):    objs[:1],
+			corev1.SchemeGroupVersion.WithKind("Namespace"): objs[1:],
+		}}, nil
+	})
+	hs := NewHookServer(testScheme(t), discardLogger(), DefaultChildNamespace("fallback"),
+		CompositeController(SyncHook(configMaps, syncer), FinalizeHook(configMaps, finalizer)))
+
+	for _, hookType := range []string{HookTypeSync, HookTypeFinalize} {
+		t.Run(hookType, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, hs.HookPath(hookType, configMaps), strings.NewReader(`{"parent":`+parentJSON+`}`))
+			w := httptest.NewRecorder()
+			hs.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+
+			var resp struct {
+				Children []struct {
+					Kind     string            `json:"kind"`
+					Metadata metav1.ObjectMeta `json:"metadata"`
+				} `json:"children"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"Secret": "default", "Namespace": ""}
+			if len(resp.Children) != len(want) {
+				t.Fatalf("got %d children, want %d", len(resp.Children), len(want))
+			}
+			for _, child := range resp.Children {
+				if child.Metadata.Namespace != want[child.Kind] {
+					t.Errorf("%s namespace = %q, want %q", child.Kind, child.Metadata.Namespace, want[child.Kind])
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeChildrenFinalize(t *testing.T) {
+	secret := func(value string) client.Object {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "s"},
+			StringData: map[string]string{"k": value},
+		}
+	}
+	tests := []struct {
+		name         string
+		children     []client.Object
+		wantCode     int
+		wantChildren int
+	}{
+		{name: "identical", children: []client.Object{secret("a"), secret("a")}, wantCode: http.StatusOK, wantChildren: 1},
+		{name: "conflicting", children: []client.Object{secret("a"), secret("b")}, wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finalizer := composition.FinalizeFunc[*corev1.ConfigMap](func(context.Context, *runtime.Scheme, *composition.FinalizeRequest[*corev1.ConfigMap]) (*composition.FinalizeResponse[*corev1.ConfigMap], error) {
+				return &composition.FinalizeResponse[*corev1.ConfigMap]{
+					Children: map[schema.GroupVersionKind][]client.Object{corev1.SchemeGroupVersion.WithKind("Secret"): tt.children},
+				}, nil
+			})
+			hs := NewHookServer(testScheme(t), discardLogger(), DedupeChildren(), CompositeController(FinalizeHook(configMaps, finalizer)))
+
+			r := httptest.NewRequest(http.MethodPost, hs.HookPath(HookTypeFinalize, configMaps), strings.NewReader(`{"parent":`+parentJSON+`}`))
+			w := httptest.NewRecorder()
+			hs.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Children []json.RawMessage `json:"children"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Children) != tt.wantChildren {
+				t.Errorf("got %d children, want %d", len(resp.Children), tt.wantChildren)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package metacontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// DispatchSyncHook registers a single sync hook at /hooks/sync that routes each
+// request to the syncer registered for the parent's apiVersion and kind. Parents
+// are decoded as *unstructured.Unstructured, and requests for kinds without a
+// syncer are rejected with 404 Not Found. Use it to serve several
+// CompositeControllers from one endpoint.
+func DispatchSyncHook(handlers map[schema.GroupVersionKind]composition.Syncer[*unstructured.Unstructured], opts ...HookOption) CompositeHook {
+	return CompositeHook(func(hs *HookServer) {
+		cfg := newHookConfig(opts)
+		dh := &dispatchSyncHandler{
+			handlers: make(map[schema.GroupVersionKind]*syncHandler[*unstructured.Unstructured], len(handlers)),
+			logger:   hs.logger,
+		}
+		for gvk, syncer := range handlers {
+			dh.handlers[gvk] = newSyncHandler(hs, schema.GroupVersionResource{}, cfg, syncer)
+		}
+		hs.handleHook(HookTypeSync, schema.GroupVersionResource{}, cfg, dh)
+	})
+}
+
+// dispatchSyncHandler routes sync hook requests by the parent's GroupVersionKind.
+type dispatchSyncHandler struct {
+	handlers map[schema.GroupVersionKind]*syncHandler[*unstructured.Unstructured]
+	logger   *slog.Logger
+}
+
+// decodeRequest implements requestDecoder. The parent is decoded by the syncer
+// registered for its kind.
+func (dh *dispatchSyncHandler) decodeRequest(r *http.Request) *hookRequest {
+	req := &hookRequest{}
+	if code, err := decodeBody(r, &req.raw); err != nil {
+		req.code, req.err = code, fmt.Errorf("SyncHook: error decoding request: %w", err)
+
+		return req
+	}
+
+	if isProtobuf(req.raw.Parent) {
+		req.code, req.err = http.StatusUnsupportedMediaType, fmt.Errorf("SyncHook: parents of a DispatchSyncHook are decoded as unstructured: %w", errNoProtobuf)
+
+		return req
+	}
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(req.raw.Parent, &typeMeta); err != nil {
+		req.code, req.err = http.StatusBadRequest, fmt.Errorf("SyncHook: error decoding parent: %w", err)
+
+		return req
+	}
+
+	gvk := typeMeta.GroupVersionKind()
+	sh, ok := dh.handlers[gvk]
+	if !ok {
+		req.code, req.err = http.StatusNotFound, fmt.Errorf("SyncHook: no syncer registered for parent kind %s", gvk)
+
+		return req
+	}
+
+	parent, code, err := decodeHookParent[*unstructured.Unstructured](sh.decoder, sh.scheme, sh.parentMatcher, req.raw.Parent, "SyncHook")
+	if err != nil {
+		req.code, req.err = code, err
+
+		return req
+	}
+	req.parent = parent
+
+	return req
+}
+
+// ServeHTTP processes sync hook HTTP requests for any registered parent kind.
+func (dh *dispatchSyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := requestFrom(r, dh)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, dh.logger)
+
+		return
+	}
+
+	parent := req.parent.(*unstructured.Unstructured)
+	dh.handlers[parent.GroupVersionKind()].serve(w, r, &req.raw, parent, schema.GroupVersion{})
+}
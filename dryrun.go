@@ -0,0 +1,217 @@
+package metacontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// DryRunHook registers a sync hook for the parent resource identified by gvr
+// that reports what syncer would do in human-readable YAML instead of
+// answering Metacontroller: the status, the children it would create, the
+// changes to children it would update (as JSON Patch operations), and the
+// children it would delete. It accepts the same requests as a sync hook and is
+// served at "/dryrun/sync/<group.resource>/<version>", e.g. for debugging a
+// controller with curl. Do not configure it as a CompositeController webhook.
+func DryRunHook[P client.Object](gvr schema.GroupVersionResource, syncer composition.Syncer[P], opts ...HookOption) CompositeHook {
+	return CompositeHook(func(hs *HookServer) {
+		cfg := newHookConfig(opts)
+		namespaces := hs.childNamespaces.withMapper(hs.restMapper)
+		if namespaces == nil {
+			namespaces = &namespaceDefaulter{mapper: hs.restMapper}
+		}
+		hs.handleHook(HookTypeDryRun, gvr, cfg, &dryRunHandler[P]{
+			sh:         newSyncHandler(hs, gvr, cfg, syncer),
+			namespaces: namespaces,
+		})
+	})
+}
+
+// dryRunHandler handles dry-run sync requests.
+type dryRunHandler[P client.Object] struct {
+	sh         *syncHandler[P]
+	namespaces *namespaceDefaulter
+}
+
+// dryRunReport is the response of a dry-run sync.
+type dryRunReport struct {
+	Parent      string            `json:"parent"`
+	Status      json.RawMessage   `json:"status,omitempty"`
+	Create      []json.RawMessage `json:"create,omitempty"`
+	Update      []dryRunUpdate    `json:"update,omitempty"`
+	Delete      []string          `json:"delete,omitempty"`
+	Finalized   bool              `json:"finalized,omitempty"`
+	ResyncAfter string            `json:"resyncAfter,omitempty"`
+}
+
+// dryRunUpdate describes the changes to an existing child.
+type dryRunUpdate struct {
+	Child   string                       `json:"child"`
+	Changes []composition.PatchOperation `json:"changes"`
+}
+
+// decodeRequest implements requestDecoder.
+func (dh *dryRunHandler[P]) decodeRequest(r *http.Request) *hookRequest {
+	return dh.sh.decodeRequest(r)
+}
+
+// checkParentType implements parentTypeChecker.
+func (dh *dryRunHandler[P]) checkParentType() error {
+	return dh.sh.checkParentType()
+}
+
+// parentType implements parentTyper.
+func (dh *dryRunHandler[P]) parentType() (schema.GroupVersionKind, reflect.Type, bool) {
+	return dh.sh.parentType()
+}
+
+// ServeHTTP runs the syncer and writes the dry-run report.
+func (dh *dryRunHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sh := dh.sh
+	req := requestFrom(r, dh)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, sh.logger)
+
+		return
+	}
+	parent := req.parent.(P)
+	r, logger := withParentLogger(r, sh.logger, HookTypeDryRun, parent)
+
+	observed, _ := decodeChildren(r.Context(), sh.childDecoder, sh.childKeys, req.raw.Children, logger, "DryRunHook")
+	related, _ := decodeChildren(r.Context(), sh.childDecoder, sh.childKeys, req.raw.Related, logger, "DryRunHook")
+	resp, err := sh.syncer.Sync(r.Context(), sh.scheme, &composition.SyncRequest[P]{
+		Controller:        req.raw.Controller,
+		Parent:            parent,
+		Children:          observed,
+		Related:           related,
+		Finalizing:        req.raw.Finalizing,
+		DeletionTimestamp: parent.GetDeletionTimestamp(),
+	})
+	if requestCanceled(r.Context(), logger, "DryRunHook") {
+		return
+	}
+	if err != nil {
+		writeError(r.Context(), w, composition.HTTPStatus(err), fmt.Errorf("DryRunHook: handler error: %w", err), logger)
+
+		return
+	}
+
+	report, err := dh.report(r.Context(), parent, observed, resp)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("DryRunHook: %w", err), logger)
+
+		return
+	}
+	data, err := yaml.Marshal(report)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("DryRunHook: error encoding report: %w", err), logger)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(data); err != nil {
+		logger.ErrorContext(r.Context(), "DryRunHook: error writing response: "+err.Error())
+	}
+}
+
+// report compares the observed children with the desired children of resp.
+// Desired children without a namespace are defaulted, and children are
+// mutated with ChildMutator, as for a sync hook before they are matched with
+// observed children.
+func (dh *dryRunHandler[P]) report(ctx context.Context, parent P, observed map[schema.GroupVersionKind][]client.Object, resp *composition.SyncResponse[P]) (*dryRunReport, error) {
+	sh := dh.sh
+	report := &dryRunReport{Parent: client.ObjectKeyFromObject(parent).String(), Finalized: resp.Finalized}
+	if resp.ResyncAfter > 0 {
+		report.ResyncAfter = resp.ResyncAfter.String()
+	}
+	if !isNilObject(resp.Status) {
+		status, err := dh.encode(resp.Status)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding status: %w", err)
+		}
+		report.Status = status
+	}
+
+	if err := dh.namespaces.apply(sh.scheme, parent, resp.Children); err != nil {
+		return nil, fmt.Errorf("error defaulting child namespaces: %w", err)
+	}
+	if err := mutateChildren(ctx, sh.mutators, parent, resp.Children); err != nil {
+		return nil, err
+	}
+	desired := make(map[schema.GroupVersionKind][]client.Object)
+	for _, child := range resp.Children {
+		gvk, err := apiutil.GVKForObject(child, sh.scheme)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving kind of child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+		}
+		desired[gvk] = append(desired[gvk], child)
+	}
+
+	create, update, del, err := composition.Diff(sh.scheme, dh.namespaces.mapper, dh.namespaces.namespace(parent), observed, desired)
+	if err != nil {
+		return nil, fmt.Errorf("error matching children: %w", err)
+	}
+	for _, child := range create {
+		data, err := dh.encode(child)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+		}
+		report.Create = append(report.Create, data)
+	}
+	for _, child := range update {
+		key, err := newChildKey(sh.scheme, child)
+		if err != nil {
+			return nil, err
+		}
+		current := findObject(observed[key.gvk], key.namespace, key.name)
+		patch, err := composition.NewChildPatch(sh.scheme, current, child)
+		if err != nil {
+			return nil, err
+		}
+		if len(patch.Patch) > 0 {
+			report.Update = append(report.Update, dryRunUpdate{Child: key.String(), Changes: patch.Patch})
+		}
+	}
+	for _, child := range del {
+		key, err := newChildKey(sh.scheme, child)
+		if err != nil {
+			return nil, err
+		}
+		report.Delete = append(report.Delete, key.String())
+	}
+
+	return report, nil
+}
+
+// encode encodes obj with the hook's encoder.
+func (dh *dryRunHandler[P]) encode(obj runtime.Object) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := encodeInto(&buf, dh.sh.encoder, obj); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// findObject returns the object in objs with the given namespace and name.
+func findObject(objs []client.Object, namespace, name string) client.Object {
+	for _, obj := range objs {
+		if obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj
+		}
+	}
+
+	return nil
+}
@@ -5,7 +5,6 @@ package main
 import (
 	"context"
 	"log"
-	"net/http"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -22,16 +21,11 @@ import (
 // sync reads a Microservice spec to create a Deployment and a Service.
 func sync(ctx context.Context, scheme *runtime.Scheme, req *composition.SyncRequest[*v1alpha1.Microservice]) (*composition.SyncResponse[*v1alpha1.Microservice], error) {
 	name := req.Parent.GetName()
-	namespace := req.Parent.GetNamespace()
-	if namespace == "" {
-		namespace = "default"
-	}
 
 	// Create a Deployment for the microservice.
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name + "-deploy",
-			Namespace: namespace,
+			Name: name + "-deploy",
 			Labels: map[string]string{
 				"app": name,
 			},
@@ -69,9 +63,8 @@ func sync(ctx context.Context, scheme *runtime.Scheme, req *composition.SyncRequ
 	// Create a Service to expose the microservice.
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name + "-svc",
-			Namespace: namespace,
-			Labels:    map[string]string{"app": name},
+			Name:   name + "-svc",
+			Labels: map[string]string{"app": name},
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{"app": name},
@@ -87,17 +80,24 @@ func sync(ctx context.Context, scheme *runtime.Scheme, req *composition.SyncRequ
 
 	// Return the result of the sync operation.
 	// In this example, we do not update the parent status.
-	return &composition.SyncResponse[*v1alpha1.Microservice]{
-		Status:   req.Parent,
-		Children: []client.Object{deployment, service},
-	}, nil
+	resp := &composition.SyncResponse[*v1alpha1.Microservice]{Status: req.Parent}
+	for _, child := range []client.Object{deployment, service} {
+		if err := resp.AddChild(scheme, child); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
 }
 
 func main() {
 	// Create a new runtime scheme.
 	scheme := runtime.NewScheme()
 
-	// Register API types for the Microservice children.
+	// Register the Microservice parent type and the API types of its children.
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		log.Fatalf("Failed to add v1alpha1 to scheme: %v", err)
+	}
 	if err := appsv1.AddToScheme(scheme); err != nil {
 		log.Fatalf("Failed to add appsv1 to scheme: %v", err)
 	}
@@ -106,7 +106,9 @@ func main() {
 	}
 
 	// Create a HookServer with our sync hook registered.
+	// Children without a namespace are placed in the parent's namespace.
 	hs := metacontroller.NewHookServer(scheme,
+		metacontroller.DefaultChildNamespace("default"),
 		metacontroller.CompositeController(
 			metacontroller.SyncHook[*v1alpha1.Microservice](
 				v1alpha1.MicroserviceGroupVersionResource,
@@ -115,8 +117,8 @@ func main() {
 		),
 	)
 
-	// Start the HookServer.
-	if err := hs.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// Run the HookServer until SIGTERM or SIGINT, then shut down gracefully.
+	if err := hs.Run(context.Background()); err != nil {
 		log.Fatalf("HookServer error: %v", err)
 	}
 }
@@ -0,0 +1,48 @@
+package metacontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// HealthCheck registers a liveness endpoint at the given path (e.g. "/healthz")
+// that responds 200 OK whenever the server is able to handle requests. The
+// endpoint is not a hook route, so hook middleware does not apply to it.
+func HealthCheck(path string) Option {
+	return func(hs *HookServer) {
+		hs.mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+			writeProbe(w, http.StatusOK, "ok")
+		})
+		hs.logger.Info("Registered health check", "path", path)
+	}
+}
+
+// ReadinessCheck registers a readiness endpoint at the given path (e.g. "/readyz")
+// that invokes check with the request context. It responds 200 OK when check
+// returns nil and 503 Service Unavailable otherwise. A nil check always reports
+// ready. The endpoint is not a hook route, so hook middleware does not apply to it.
+func ReadinessCheck(path string, check func(context.Context) error) Option {
+	return func(hs *HookServer) {
+		hs.mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+			if check != nil {
+				if err := check(r.Context()); err != nil {
+					hs.logger.WarnContext(r.Context(), "Readiness check failed", "error", err.Error())
+					writeProbe(w, http.StatusServiceUnavailable, "not ready")
+
+					return
+				}
+			}
+			writeProbe(w, http.StatusOK, "ok")
+		})
+		hs.logger.Info("Registered readiness check", "path", path)
+	}
+}
+
+// writeProbe writes a plain-text probe response.
+func writeProbe(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, body)
+}
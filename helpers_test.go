@@ -0,0 +1,68 @@
+package metacontroller
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// configMaps is the parent resource of the hooks registered by tests.
+var configMaps = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// testScheme returns a scheme with the core/v1 types registered.
+func testScheme(t testing.TB) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return scheme
+}
+
+// discardLogger returns an option that discards the server's logs.
+func discardLogger() Option {
+	return Logger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// syncFunc adapts fn to a ConfigMap Syncer.
+func syncFunc(fn func(ctx context.Context, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error)) composition.Syncer[*corev1.ConfigMap] {
+	return composition.SyncerFunc[*corev1.ConfigMap](func(ctx context.Context, _ *runtime.Scheme, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return fn(ctx, req)
+	})
+}
+
+// newSyncServer returns a HookServer with syncer registered as the sync hook of
+// configMaps.
+func newSyncServer(t testing.TB, syncer composition.Syncer[*corev1.ConfigMap], opts ...Option) *HookServer {
+	t.Helper()
+	opts = append([]Option{discardLogger()}, opts...)
+	opts = append(opts, CompositeController(SyncHook(configMaps, syncer)))
+
+	return NewHookServer(testScheme(t), opts...)
+}
+
+// parentJSON is a ConfigMap parent in Metacontroller's wire format.
+const parentJSON = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"parent","namespace":"default"}}`
+
+// postSync sends body to the sync hook of hs and returns the response.
+func postSync(hs *HookServer, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, hs.HookPath(HookTypeSync, configMaps), strings.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	hs.ServeHTTP(w, r)
+
+	return w
+}
@@ -7,10 +7,21 @@ package metacontroller
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -21,12 +32,65 @@ import (
 
 // HookServer is an HTTP server that hosts one or more Metacontroller hook servers.
 type HookServer struct {
-	addr   string
-	scheme *runtime.Scheme
-	codecs serializer.CodecFactory
-	mux    *http.ServeMux
-	server *http.Server
-	logger *slog.Logger
+	addr                string
+	tlsConfig           *tls.Config
+	scheme              *runtime.Scheme
+	codecs              serializer.CodecFactory
+	mux                 *http.ServeMux
+	server              *http.Server
+	logger              *slog.Logger
+	hooks               []CompositeHook
+	metrics             MetricsRecorder
+	timeout             time.Duration
+	recover             bool
+	allowUnstructured   bool
+	strictChildren      bool
+	maxRequestBytes     int64
+	middleware          []func(http.Handler) http.Handler
+	verifyToken         func(context.Context, string) error
+	strictDecoding      bool
+	shutdownTimeout     time.Duration
+	drainTimeout        time.Duration
+	tracer              Tracer
+	dedupeChildren      bool
+	pathTemplate        func(hookType string, gvr schema.GroupVersionResource) string
+	readTimeout         time.Duration
+	baseContext         func(net.Listener) context.Context
+	connContext         func(context.Context, net.Conn) context.Context
+	readHeaderTimeout   time.Duration
+	writeTimeout        time.Duration
+	idleTimeout         time.Duration
+	concurrency         limiter
+	jsonErrors          bool
+	accessLog           bool
+	accessLogger        *slog.Logger
+	childNamespaces     *namespaceDefaulter
+	explicitPrune       bool
+	childMutators       []func(ctx context.Context, parent, child client.Object) error
+	childKey            func(schema.GroupVersionKind) string
+	crossNamespace      bool
+	restMapper          meta.RESTMapper
+	summaryLogs         bool
+	parentPatcher       client.Client
+	validateResponses   bool
+	preprocess          func(io.Reader) io.Reader
+	onRequest           func(ctx context.Context, hookType string, body []byte)
+	hmacSecret          []byte
+	hmacHeader          string
+	requiredHeaders     []requiredHeader
+	compressionMinBytes int
+	syncCache           *responseCache
+
+	mu       sync.Mutex
+	state    serverState
+	inFlight atomic.Int64
+
+	hooksMu   sync.RWMutex
+	endpoints map[string]hookEndpoint
+	routes    []RouteInfo
+
+	lastSyncMu sync.Mutex
+	lastSync   map[schema.GroupVersionResource]time.Time
 }
 
 // NewHookServer creates a new HookServer that will listen on the provided address
@@ -34,15 +98,29 @@ type HookServer struct {
 // register the various hook endpoints.
 func NewHookServer(scheme *runtime.Scheme, opts ...Option) *HookServer {
 	hs := &HookServer{
-		addr:   ":8080",
-		scheme: scheme,
-		mux:    http.NewServeMux(),
-		logger: slog.Default(),
+		addr:              ":8080",
+		scheme:            scheme,
+		mux:               http.NewServeMux(),
+		endpoints:         make(map[string]hookEndpoint),
+		lastSync:          make(map[schema.GroupVersionResource]time.Time),
+		logger:            slog.Default(),
+		recover:           true,
+		maxRequestBytes:   DefaultMaxRequestBytes,
+		shutdownTimeout:   DefaultShutdownTimeout,
+		readTimeout:       DefaultReadTimeout,
+		readHeaderTimeout: DefaultReadHeaderTimeout,
+		idleTimeout:       DefaultIdleTimeout,
+		childKey:          composition.KeyForGVK,
 	}
 	hs.codecs = serializer.NewCodecFactory(scheme)
 	for _, opt := range opts {
 		opt(hs)
 	}
+	// Hooks are registered after all other options have been applied so that
+	// server-wide settings apply regardless of option order.
+	for _, hook := range hs.hooks {
+		hook(hs)
+	}
 
 	return hs
 }
@@ -57,6 +135,156 @@ func Addr(addr string) Option {
 	}
 }
 
+// HookTimeout sets the maximum duration of each hook request. The request
+// context passed to the Syncer, Finalizer, or Customizer is canceled once the
+// timeout elapses, and the server responds 503 Service Unavailable. Individual
+// hooks may override it with WithTimeout. (Default: no timeout)
+func HookTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.timeout = d
+	}
+}
+
+// DefaultMaxRequestBytes is the default limit on the size of a hook request body.
+const DefaultMaxRequestBytes int64 = 10 << 20
+
+// MaxRequestBytes limits the size of hook request bodies to n bytes. Larger
+// requests are rejected with 413 Request Entity Too Large. A value of zero or
+// less disables the limit. (Default: DefaultMaxRequestBytes, 10 MiB)
+func MaxRequestBytes(n int64) Option {
+	return func(hs *HookServer) {
+		hs.maxRequestBytes = n
+	}
+}
+
+// RequestPreprocessor creates an option that passes every hook request body
+// through fn before it is decoded, e.g. to normalize bodies rewritten by an
+// intermediate proxy. fn reads from the body after the MaxRequestBytes limit is
+// applied. A leading UTF-8 byte order mark and whitespace are always tolerated,
+// so fn is not needed for those.
+func RequestPreprocessor(fn func(io.Reader) io.Reader) Option {
+	return func(hs *HookServer) {
+		hs.preprocess = fn
+	}
+}
+
+// OnRequest creates an option that calls fn with a copy of every hook request
+// body, e.g. to persist the payloads Metacontroller sends for auditing. fn runs
+// before the request is decoded, so it also sees requests that fail to decode,
+// and receives the body after decompression but before any
+// RequestPreprocessor. fn runs synchronously and must not retain ctx beyond
+// the call.
+func OnRequest(fn func(ctx context.Context, hookType string, body []byte)) Option {
+	return func(hs *HookServer) {
+		hs.onRequest = fn
+	}
+}
+
+// RecoverPanics enables or disables recovery from panics raised by hook
+// handlers. When enabled, a panic is logged with its stack trace and the server
+// responds 500 Internal Server Error instead of dropping the connection.
+// (Default: true)
+func RecoverPanics(enabled bool) Option {
+	return func(hs *HookServer) {
+		hs.recover = enabled
+	}
+}
+
+// DefaultShutdownTimeout is the default grace period Run allows in-flight
+// requests to complete during shutdown.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ShutdownTimeout sets the grace period Run allows in-flight requests to
+// complete once shutdown begins. (Default: DefaultShutdownTimeout, 30s)
+func ShutdownTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.shutdownTimeout = d
+	}
+}
+
+// DrainTimeout bounds how long Shutdown waits for in-flight hook requests to
+// complete. Once d elapses, Shutdown closes the remaining connections and
+// returns an error wrapping ErrForcedShutdown. A zero duration waits until the
+// context passed to Shutdown is done. (Default: 0)
+func DrainTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.drainTimeout = d
+	}
+}
+
+// Default timeouts of the underlying http.Server.
+const (
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+)
+
+// ReadTimeout sets the maximum duration for reading an entire request,
+// including the body. A zero duration disables the timeout.
+// (Default: DefaultReadTimeout, 30s)
+func ReadTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.readTimeout = d
+	}
+}
+
+// ReadHeaderTimeout sets the maximum duration for reading request headers,
+// which protects the server against slow clients holding connections open. A
+// zero duration falls back to ReadTimeout. (Default: DefaultReadHeaderTimeout, 10s)
+func ReadHeaderTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.readHeaderTimeout = d
+	}
+}
+
+// WriteTimeout sets the maximum duration before timing out writes of the
+// response, measured from the end of the request headers. It bounds the time a
+// hook may run, so prefer HookTimeout to limit hooks and keep WriteTimeout above
+// it. A zero duration disables the timeout. (Default: no timeout)
+func WriteTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.writeTimeout = d
+	}
+}
+
+// IdleTimeout sets the maximum duration to wait for the next request on a
+// keep-alive connection. A zero duration falls back to ReadTimeout.
+// (Default: DefaultIdleTimeout, 120s)
+func IdleTimeout(d time.Duration) Option {
+	return func(hs *HookServer) {
+		hs.idleTimeout = d
+	}
+}
+
+// TLSConfig sets the TLS configuration used by ListenAndServeTLS. Use it to
+// require client certificates (mTLS), raise the minimum TLS version, or restrict
+// cipher suites. The configuration is ignored by ListenAndServe.
+func TLSConfig(cfg *tls.Config) Option {
+	return func(hs *HookServer) {
+		hs.tlsConfig = cfg
+	}
+}
+
+// BaseContext sets the function that returns the base context of every request
+// served by ListenAndServe, ListenAndServeTLS, and Run, as http.Server.BaseContext.
+// Values attached to it, e.g. shared clients or configuration, are visible to
+// hooks through the context passed to them. (Default: context.Background())
+func BaseContext(fn func(net.Listener) context.Context) Option {
+	return func(hs *HookServer) {
+		hs.baseContext = fn
+	}
+}
+
+// ConnContext sets the function that modifies the context of each new
+// connection served by ListenAndServe, ListenAndServeTLS, and Run, as
+// http.Server.ConnContext, e.g. to attach per-connection values derived from
+// the remote address.
+func ConnContext(fn func(ctx context.Context, c net.Conn) context.Context) Option {
+	return func(hs *HookServer) {
+		hs.connContext = fn
+	}
+}
+
 // Logger creates an option that sets a custom logger for the HookServer. (Default: slog.Default())
 func Logger(logger *slog.Logger) Option {
 	return func(hs *HookServer) {
@@ -64,77 +292,431 @@ func Logger(logger *slog.Logger) Option {
 	}
 }
 
+// Hook types identify the kind of Metacontroller hook served by an endpoint.
+const (
+	HookTypeSync      = "sync"
+	HookTypeFinalize  = "finalize"
+	HookTypeCustomize = "customize"
+	// HookTypeDryRun identifies hooks registered with DryRunHook, which are
+	// not Metacontroller hooks.
+	HookTypeDryRun = "dryrun"
+)
+
 // CompositeHook is a functional option that registers a CompositeController hook with the HookServer.
 type CompositeHook Option
 
+// CompositeController registers the given CompositeController hooks. Hooks are
+// registered once all other options have been applied.
 func CompositeController(hooks ...CompositeHook) Option {
 	return func(hs *HookServer) {
-		for _, hook := range hooks {
-			hook(hs)
-		}
+		hs.hooks = append(hs.hooks, hooks...)
 	}
 }
 
-func SyncHook[P client.Object](gvr schema.GroupVersionResource, syncer composition.Syncer[P]) CompositeHook {
+// CompositeHooks groups the hooks of a single CompositeController. Sync is
+// required; Finalize and Customize are registered only when set.
+type CompositeHooks[P client.Object] struct {
+	Sync      composition.Syncer[P]
+	Finalize  composition.Finalizer[P]
+	Customize composition.Customizer[P]
+}
+
+// RegisterCompositeController registers every hook set in hooks for the parent
+// resource identified by gvr, applying opts to each of them. It panics if
+// hooks.Sync is nil. The individual SyncHook, FinalizeHook, and CustomizeHook
+// registrations remain available for advanced use.
+func RegisterCompositeController[P client.Object](gvr schema.GroupVersionResource, hooks CompositeHooks[P], opts ...HookOption) Option {
+	if hooks.Sync == nil {
+		panic(fmt.Sprintf("metacontroller: RegisterCompositeController for %s requires a Sync hook", gvr.String()))
+	}
+
+	registrations := []CompositeHook{SyncHook(gvr, hooks.Sync, opts...)}
+	if hooks.Finalize != nil {
+		registrations = append(registrations, FinalizeHook(gvr, hooks.Finalize, opts...))
+	}
+	if hooks.Customize != nil {
+		registrations = append(registrations, CustomizeHook(gvr, hooks.Customize, opts...))
+	}
+
+	return CompositeController(registrations...)
+}
+
+// HookOption configures an individual hook registration.
+type HookOption func(*hookConfig)
+
+// hookConfig holds the per-hook settings applied by HookOptions.
+type hookConfig struct {
+	timeout              *time.Duration
+	unstructuredChildren bool
+	childTypes           map[schema.GroupVersionKind]func() client.Object
+	maxConcurrent        int
+	encoder              runtime.Encoder
+	scheme               *runtime.Scheme
+}
+
+// newHookConfig applies the given HookOptions to a new hookConfig.
+func newHookConfig(opts []HookOption) hookConfig {
+	var cfg hookConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// WithTimeout overrides the server-wide HookTimeout for a single hook. A zero
+// duration disables the timeout for the hook.
+func WithTimeout(d time.Duration) HookOption {
+	return func(cfg *hookConfig) {
+		cfg.timeout = &d
+	}
+}
+
+// PreserveUnknownFields decodes the hook's observed children as
+// *unstructured.Unstructured rather than their typed Go representation, so
+// fields the compiled types do not model survive being returned unchanged.
+func PreserveUnknownFields() HookOption {
+	return func(cfg *hookConfig) {
+		cfg.unstructuredChildren = true
+	}
+}
+
+// SyncHook registers a sync hook for the parent resource identified by gvr.
+// Hooks for different versions of the same resource are served at separate
+// paths, and each decodes and encodes the parent as its own version's type, so
+// a resource served at several versions can have a hook per version.
+// ConvertingSyncHook instead serves every version with a single Syncer.
+func SyncHook[P client.Object](gvr schema.GroupVersionResource, syncer composition.Syncer[P], opts ...HookOption) CompositeHook {
 	return CompositeHook(func(hs *HookServer) {
-		resource := fmt.Sprintf("%s/%s", gvr.GroupResource().String(), gvr.Version)
-		path := "/hooks/sync/" + resource
-		hs.mux.Handle("POST "+path, &syncHandler[P]{
-			scheme:  hs.scheme,
-			decoder: hs.codecs.UniversalDecoder(),
-			encoder: hs.codecs.LegacyCodec(gvr.GroupVersion()),
-			syncer:  syncer,
-			logger:  hs.logger,
-		})
-		hs.logger.Info("Registered sync hook at %q for %q", path, gvr.String())
+		cfg := newHookConfig(opts)
+		hs.handleHook(HookTypeSync, gvr, cfg, newSyncHandler(hs, gvr, cfg, syncer))
 	})
 }
 
-func FinalizeHook[P client.Object](gvr schema.GroupVersionResource, finalizer composition.Finalizer[P]) CompositeHook {
+// FinalizeHook registers a finalize hook for the parent resource identified by gvr.
+func FinalizeHook[P client.Object](gvr schema.GroupVersionResource, finalizer composition.Finalizer[P], opts ...HookOption) CompositeHook {
 	return CompositeHook(func(hs *HookServer) {
-		resource := fmt.Sprintf("%s/%s", gvr.GroupResource().String(), gvr.Version)
-		path := "/hooks/finalize/" + resource
-		hs.mux.Handle("POST "+path, &finalizeHandler[P]{
-			scheme:    hs.scheme,
-			decoder:   hs.codecs.UniversalDecoder(),
-			finalizer: finalizer,
-			logger:    hs.logger,
+		cfg := newHookConfig(opts)
+		hs.handleHook(HookTypeFinalize, gvr, cfg, &finalizeHandler[P]{
+			scheme:          hs.hookScheme(cfg),
+			decoder:         hs.parentDecoder(cfg),
+			childDecoder:    hs.childDecoder(cfg),
+			childKeys:       hs.childKeys(cfg),
+			encoder:         hs.encoder(cfg),
+			protoEncoder:    hs.protobufEncoder(cfg),
+			finalizer:       finalizer,
+			logger:          hs.logger,
+			strictChildren:  hs.strictChildren,
+			parentMatcher:   parentMatcher{gvr: gvr, mapper: hs.restMapper},
+			validate:        hs.validateResponses,
+			childNamespaces: hs.childNamespaces.withMapper(hs.restMapper),
+			crossNamespace:  hs.crossNamespace,
+			dedupeChildren:  hs.dedupeChildren,
+			mutators:        hs.childMutators,
 		})
-		hs.logger.Info("Registered finalize hook at %q for %q", path, gvr.String())
 	})
 }
 
-func CustomizeHook[P client.Object](gvr schema.GroupVersionResource, customizer composition.Customizer[P]) CompositeHook {
+// CustomizeHook registers a customize hook for the parent resource identified by gvr.
+func CustomizeHook[P client.Object](gvr schema.GroupVersionResource, customizer composition.Customizer[P], opts ...HookOption) CompositeHook {
 	return CompositeHook(func(hs *HookServer) {
-		resource := fmt.Sprintf("%s/%s", gvr.GroupResource().String(), gvr.Version)
-		path := "/hooks/customize/" + resource
-		hs.mux.Handle("POST "+path, &customizeHandler[P]{
-			scheme:     hs.scheme,
-			decoder:    hs.codecs.UniversalDecoder(),
-			customizer: customizer,
-			logger:     hs.logger,
+		cfg := newHookConfig(opts)
+		hs.handleHook(HookTypeCustomize, gvr, cfg, &customizeHandler[P]{
+			scheme:        hs.hookScheme(cfg),
+			decoder:       hs.parentDecoder(cfg),
+			customizer:    customizer,
+			logger:        hs.logger,
+			parentMatcher: parentMatcher{gvr: gvr, mapper: hs.restMapper},
+			validate:      hs.validateResponses,
 		})
-		hs.logger.Info("Registered customize hook at %q for %q", path, gvr.String())
 	})
 }
 
+// hookRoute describes a registered hook endpoint.
+type hookRoute struct {
+	hookType string
+	gvr      schema.GroupVersionResource
+	path     string
+	config   hookConfig
+}
+
+// resource returns the "<group.resource>/<version>" name of the route's parent
+// resource, or "*" for routes that serve several parent resources.
+func (rt hookRoute) resource() string {
+	if rt.gvr.Empty() {
+		return "*"
+	}
+
+	return fmt.Sprintf("%s/%s", rt.gvr.GroupResource().String(), rt.gvr.Version)
+}
+
+// PathTemplate customizes the path at which each hook is served. The template is
+// called with the hook type and the parent resource, which is empty for hooks
+// such as DispatchSyncHook that serve several resources. Paths must include the
+// version to serve hooks for several versions of a resource; a hook registered
+// at the path of another replaces it.
+// (Default: "/hooks/<type>/<group.resource>/<version>", or "/hooks/<type>" for an empty resource,
+// and "/dryrun/sync/<group.resource>/<version>" for DryRunHook)
+func PathTemplate(template func(hookType string, gvr schema.GroupVersionResource) string) Option {
+	return func(hs *HookServer) {
+		hs.pathTemplate = template
+	}
+}
+
+// HookPath returns the path at which the hook of the given type is served for
+// the parent resource identified by gvr.
+func (hs *HookServer) HookPath(hookType string, gvr schema.GroupVersionResource) string {
+	if hs.pathTemplate != nil {
+		return hs.pathTemplate(hookType, gvr)
+	}
+	if hookType == HookTypeDryRun {
+		return "/dryrun/sync/" + hookRoute{gvr: gvr}.resource()
+	}
+	if gvr.Empty() {
+		return "/hooks/" + hookType
+	}
+
+	return "/hooks/" + hookType + "/" + hookRoute{gvr: gvr}.resource()
+}
+
+// Handler returns the http.Handler that serves the registered endpoints, so the
+// hooks can be mounted into an existing server or router. When the HookServer is
+// used this way, the Addr, TLSConfig, BaseContext, and ConnContext options are
+// ignored and ListenAndServe, ListenAndServeTLS, and Shutdown need not be called.
+func (hs *HookServer) Handler() http.Handler {
+	return hs
+}
+
+// ServeHTTP implements http.Handler. Hook requests are served by the hook
+// registered at the request path; other requests (health checks, metrics, and
+// profiling) are served by the server's multiplexer.
+func (hs *HookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hs.hooksMu.RLock()
+	ep, ok := hs.endpoints[r.URL.Path]
+	hs.hooksMu.RUnlock()
+	if !ok {
+		hs.mux.ServeHTTP(w, r)
+
+		return
+	}
+	if r.Method != http.MethodPost {
+		ep.notAllowed.ServeHTTP(w, r)
+
+		return
+	}
+	hs.inFlight.Add(1)
+	defer hs.inFlight.Add(-1)
+	ep.handler.ServeHTTP(w, r)
+}
+
+// ErrServerStopped is returned by ListenAndServe, ListenAndServeTLS, and Run
+// once the HookServer has been shut down. A HookServer cannot be restarted.
+var ErrServerStopped = errors.New("metacontroller: HookServer has been shut down")
+
+// ErrForcedShutdown is returned by Shutdown and Run when hook requests were
+// still in flight after the DrainTimeout and their connections were closed.
+var ErrForcedShutdown = errors.New("metacontroller: HookServer closed with hook requests in flight")
+
+// ErrServerRunning is returned by ListenAndServe, ListenAndServeTLS, and Run if
+// the HookServer is already serving.
+var ErrServerRunning = errors.New("metacontroller: HookServer is already running")
+
+// serverState is the lifecycle state of a HookServer's HTTP server.
+type serverState int
+
+const (
+	serverNotStarted serverState = iota
+	serverRunning
+	serverStopped
+)
+
 // ListenAndServe starts the HTTP server with the registered endpoints.
 func (hs *HookServer) ListenAndServe() error {
-	hs.server = &http.Server{
-		Addr:    hs.addr,
-		Handler: hs.mux,
+	server, err := hs.start()
+	if err != nil {
+		return err
 	}
 	hs.logger.Info("Starting HookServer at " + hs.addr)
 
-	return hs.server.ListenAndServe()
+	return hs.served(server, server.ListenAndServe())
 }
 
-// Shutdown gracefully shuts down the HTTP server using the provided context.
-func (hs *HookServer) Shutdown(ctx context.Context) error {
-	if hs.server != nil {
-		hs.logger.Info("Shutting down HookServer at " + hs.addr)
-		return hs.server.Shutdown(ctx)
+// ListenAndServeTLS starts the HTTPS server with the registered endpoints using
+// the given certificate and key files. Both may be empty if the TLSConfig option
+// supplies certificates via Certificates or GetCertificate.
+func (hs *HookServer) ListenAndServeTLS(certFile, keyFile string) error {
+	server, err := hs.start()
+	if err != nil {
+		return err
+	}
+	hs.logger.Info("Starting HookServer with TLS at " + hs.addr)
+
+	return hs.served(server, server.ListenAndServeTLS(certFile, keyFile))
+}
+
+// Run starts the server and blocks until ctx is canceled or the process
+// receives SIGTERM or SIGINT, then shuts the server down gracefully, allowing
+// in-flight requests up to the ShutdownTimeout to complete. If the TLSConfig
+// option is set, Run serves HTTPS using the certificates from that
+// configuration. Run returns nil on a clean shutdown and the serve or shutdown
+// error otherwise.
+func (hs *HookServer) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server, err := hs.start()
+	if err != nil {
+		return err
+	}
+	serve := server.ListenAndServe
+	if hs.tlsConfig != nil {
+		serve = func() error { return server.ListenAndServeTLS("", "") }
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		hs.logger.Info("Starting HookServer at " + hs.addr)
+		errc <- hs.served(server, serve())
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hs.shutdownTimeout)
+	defer cancel()
+	if err := hs.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
 
 	return nil
 }
+
+// RunAll runs servers with Run until ctx is canceled, the process receives
+// SIGTERM or SIGINT, or one of them fails, e.g. a plaintext health server and a
+// TLS hook server on separate addresses. Once any server stops, the others are
+// shut down gracefully. RunAll waits for every server to stop and returns the
+// first error, or nil if all shut down cleanly.
+func RunAll(ctx context.Context, servers ...*HookServer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, hs := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			if err := hs.Run(ctx); err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// start transitions the HookServer to running and creates its http.Server.
+func (hs *HookServer) start() (*http.Server, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	switch hs.state {
+	case serverRunning:
+		return nil, ErrServerRunning
+	case serverStopped:
+		return nil, ErrServerStopped
+	}
+	if err := hs.Validate(); err != nil {
+		return nil, err
+	}
+	hs.server = hs.newServer()
+	hs.state = serverRunning
+
+	return hs.server, nil
+}
+
+// served returns err, the error server stopped serving with. Unless the server
+// was shut down, it failed to start (e.g. because the address is in use) or
+// stopped unexpectedly, so the HookServer returns to not started and may be
+// started again.
+func (hs *HookServer) served(server *http.Server, err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.state == serverRunning && hs.server == server {
+		hs.state = serverNotStarted
+		hs.server = nil
+	}
+
+	return err
+}
+
+// newServer creates the underlying http.Server for the HookServer.
+func (hs *HookServer) newServer() *http.Server {
+	server := &http.Server{
+		Addr:              hs.addr,
+		Handler:           hs.Handler(),
+		ReadTimeout:       hs.readTimeout,
+		ReadHeaderTimeout: hs.readHeaderTimeout,
+		WriteTimeout:      hs.writeTimeout,
+		IdleTimeout:       hs.idleTimeout,
+		BaseContext:       hs.baseContext,
+		ConnContext:       hs.connContext,
+	}
+	if hs.tlsConfig != nil {
+		server.TLSConfig = hs.tlsConfig
+	}
+
+	return server
+}
+
+// Shutdown gracefully shuts down the HTTP server using the provided context,
+// waiting for in-flight hook requests to complete. With the DrainTimeout
+// option, connections still open once it elapses are closed and an error
+// wrapping ErrForcedShutdown is returned. It is a no-op if the server has not
+// been started or has already been shut down. Once shut down, the HookServer
+// cannot be started again.
+func (hs *HookServer) Shutdown(ctx context.Context) error {
+	hs.mu.Lock()
+	if hs.state != serverRunning {
+		hs.mu.Unlock()
+
+		return nil
+	}
+	hs.state = serverStopped
+	server := hs.server
+	hs.mu.Unlock()
+
+	hs.logger.Info("Shutting down HookServer at "+hs.addr, "inFlight", hs.inFlight.Load())
+	if hs.drainTimeout <= 0 {
+		return server.Shutdown(ctx)
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, hs.drainTimeout)
+	defer cancel()
+	err := server.Shutdown(drainCtx)
+	if err == nil || drainCtx.Err() == nil {
+		return err
+	}
+	inFlight := hs.inFlight.Load()
+	hs.logger.Warn("Drain timed out; closing HookServer connections", "inFlight", inFlight)
+	if closeErr := server.Close(); closeErr != nil {
+		return fmt.Errorf("%w (%d requests): %w", ErrForcedShutdown, inFlight, closeErr)
+	}
+
+	return fmt.Errorf("%w (%d requests): %w", ErrForcedShutdown, inFlight, err)
+}
@@ -0,0 +1,221 @@
+package metacontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// serve runs hs.ListenAndServe in a goroutine and returns the channel its
+// error is sent on.
+func serve(hs *HookServer) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- hs.ListenAndServe()
+	}()
+
+	return errc
+}
+
+// waitServed returns the error sent on errc, failing the test if none is sent
+// in time.
+func waitServed(t *testing.T, errc <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return")
+
+		return nil
+	}
+}
+
+// waitRunning waits until hs has been started.
+func waitRunning(t *testing.T, hs *HookServer) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hs.mu.Lock()
+		state := hs.state
+		hs.mu.Unlock()
+		if state == serverRunning {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("HookServer did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHookServerCannotRestartAfterShutdown(t *testing.T) {
+	hs := NewHookServer(testScheme(t), discardLogger(), Addr("127.0.0.1:0"))
+	errc := serve(hs)
+	waitRunning(t, hs)
+
+	if err := hs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := waitServed(t, errc); !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("ListenAndServe() error = %v, want %v", err, http.ErrServerClosed)
+	}
+	if err := hs.ListenAndServe(); !errors.Is(err, ErrServerStopped) {
+		t.Errorf("ListenAndServe() after Shutdown error = %v, want %v", err, ErrServerStopped)
+	}
+}
+
+func TestHookServerShutdownTwice(t *testing.T) {
+	hs := NewHookServer(testScheme(t), discardLogger(), Addr("127.0.0.1:0"))
+	errc := serve(hs)
+	waitRunning(t, hs)
+
+	for i := range 2 {
+		if err := hs.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown() #%d error = %v", i+1, err)
+		}
+	}
+	if err := waitServed(t, errc); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("ListenAndServe() error = %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestHookServerRestartsAfterListenFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := NewHookServer(testScheme(t), discardLogger(), Addr(ln.Addr().String()))
+
+	if err := hs.ListenAndServe(); err == nil || errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("ListenAndServe() on a used address error = %v, want a listen error", err)
+	}
+	if err := hs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() of a server that failed to start error = %v", err)
+	}
+
+	ln.Close()
+	errc := serve(hs)
+	waitRunning(t, hs)
+	if err := hs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := waitServed(t, errc); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("ListenAndServe() after a failed start error = %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+// widgetV1 is version v1 of a test parent kind, Widget.
+type widgetV1 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Size              int `json:"size,omitempty"`
+}
+
+func (w *widgetV1) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	return &out
+}
+
+// widgetV2 is version v2 of Widget, which renames its size field.
+type widgetV2 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Replicas          int `json:"replicas,omitempty"`
+}
+
+func (w *widgetV2) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	return &out
+}
+
+func TestSyncHooksForSeveralVersions(t *testing.T) {
+	v1 := schema.GroupVersion{Group: "example.com", Version: "v1"}
+	v2 := schema.GroupVersion{Group: "example.com", Version: "v2"}
+	scheme := testScheme(t)
+	scheme.AddKnownTypeWithName(v1.WithKind("Widget"), &widgetV1{})
+	scheme.AddKnownTypeWithName(v2.WithKind("Widget"), &widgetV2{})
+
+	var gotV1 *composition.SyncRequest[*widgetV1]
+	var gotV2 *composition.SyncRequest[*widgetV2]
+	hs := NewHookServer(scheme, discardLogger(), CompositeController(
+		SyncHook(v1.WithResource("widgets"), composition.SyncerFunc[*widgetV1](func(_ context.Context, _ *runtime.Scheme, req *composition.SyncRequest[*widgetV1]) (*composition.SyncResponse[*widgetV1], error) {
+			gotV1 = req
+
+			return &composition.SyncResponse[*widgetV1]{Status: req.Parent}, nil
+		})),
+		SyncHook(v2.WithResource("widgets"), composition.SyncerFunc[*widgetV2](func(_ context.Context, _ *runtime.Scheme, req *composition.SyncRequest[*widgetV2]) (*composition.SyncResponse[*widgetV2], error) {
+			gotV2 = req
+
+			return &composition.SyncResponse[*widgetV2]{Status: req.Parent}, nil
+		})),
+	))
+	if err := hs.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// Both versions appear as children too, and must stay separate kinds.
+	children := `"children":{
+		"Widget.example.com/v1":{"default/c1":{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"c1","namespace":"default"},"size":1}},
+		"Widget.example.com/v2":{"default/c2":{"apiVersion":"example.com/v2","kind":"Widget","metadata":{"name":"c2","namespace":"default"},"replicas":2}}}`
+	tests := []struct {
+		gv     schema.GroupVersion
+		parent string
+		field  string
+	}{
+		{gv: v1, parent: `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w","namespace":"default"},"size":3}`, field: "size"},
+		{gv: v2, parent: `{"apiVersion":"example.com/v2","kind":"Widget","metadata":{"name":"w","namespace":"default"},"replicas":3}`, field: "replicas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gv.Version, func(t *testing.T) {
+			path := hs.HookPath(HookTypeSync, tt.gv.WithResource("widgets"))
+			r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"parent":`+tt.parent+`,`+children+`}`))
+			w := httptest.NewRecorder()
+			hs.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST %s status = %d, want %d: %s", path, w.Code, http.StatusOK, w.Body)
+			}
+
+			var resp struct {
+				Status map[string]any `json:"status"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status["apiVersion"] != tt.gv.String() || resp.Status[tt.field] != float64(3) {
+				t.Errorf("status = %v, want apiVersion %s with %s 3", resp.Status, tt.gv, tt.field)
+			}
+		})
+	}
+
+	if gotV1 == nil || gotV1.Parent.Size != 3 {
+		t.Errorf("v1 hook parent = %+v, want size 3", gotV1)
+	}
+	if gotV2 == nil || gotV2.Parent.Replicas != 3 {
+		t.Errorf("v2 hook parent = %+v, want replicas 3", gotV2)
+	}
+	for _, gv := range []schema.GroupVersion{v1, v2} {
+		if gotV2 == nil {
+			break
+		}
+		if n := len(gotV2.Children[gv.WithKind("Widget")]); n != 1 {
+			t.Errorf("children of kind %s = %d, want 1", gv.WithKind("Widget"), n)
+		}
+	}
+}
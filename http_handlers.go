@@ -1,11 +1,17 @@
 package metacontroller
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"reflect"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,31 +20,24 @@ import (
 	"github.com/a2y-d5l/go-metacontroller/composition"
 )
 
-type (
-	// rawCompositeRequest mirrors the JSON payload for the sync hook.
-	rawCompositeRequest struct {
-		Parent     json.RawMessage                       `json:"parent"`
-		Children   map[string]map[string]json.RawMessage `json:"children,omitempty"`
-		Finalizing bool                                  `json:"finalizing"`
-	}
-
-	// rawCompositeResponse is used to encode the sync hook response.
-	rawCompositeResponse struct {
-		Status    json.RawMessage   `json:"status,omitempty"`
-		Children  []json.RawMessage `json:"children,omitempty"`
-		Finalized bool              `json:"finalized,omitempty"`
-	}
-
-	// rawCustomizeRequest mirrors the JSON payload for the customize hook.
-	rawCustomizeRequest struct {
-		Controller json.RawMessage `json:"controller"`
-		Parent     json.RawMessage `json:"parent"`
-	}
-)
+// rawCompositeRequest mirrors the JSON payload of a hook request. Customize
+// requests carry only the controller and parent.
+type rawCompositeRequest struct {
+	Controller json.RawMessage                       `json:"controller,omitempty"`
+	Parent     json.RawMessage                       `json:"parent"`
+	Children   map[string]map[string]json.RawMessage `json:"children,omitempty"`
+	Related    map[string]map[string]json.RawMessage `json:"related,omitempty"`
+	Finalizing bool                                  `json:"finalizing"`
+}
 
-// writeError logs an error and writes an HTTP error response. If debug is true, the detailed error message is exposed in the response.
+// writeError logs an error and writes an HTTP error response, as JSON when the
+// JSONErrors option is set. If debug logging is enabled, the detailed error
+// message is exposed in the response.
 func writeError(ctx context.Context, w http.ResponseWriter, code int, err error, logger *slog.Logger) {
-	slog.Error("Error: " + err.Error())
+	logger.ErrorContext(ctx, "Error: "+err.Error())
+	if span, ok := spanFromContext(ctx); ok {
+		span.RecordError(err)
+	}
 	var msg string
 	switch code {
 	case http.StatusBadRequest:
@@ -54,223 +53,632 @@ func writeError(ctx context.Context, w http.ResponseWriter, code int, err error,
 	if logger.Enabled(ctx, slog.LevelDebug) {
 		msg = fmt.Sprintf("%s: %v", msg, err)
 	}
+	if jsonErrorsEnabled(ctx) {
+		writeJSONError(w, code, msg)
+
+		return
+	}
 	http.Error(w, msg, code)
 }
 
+// methodNotAllowed returns a handler that responds 405 Method Not Allowed with
+// an Allow header listing the allowed methods. It serves requests to hook paths
+// with other methods, and honors the JSONErrors option.
+func (hs *HookServer) methodNotAllowed(allowed ...string) http.Handler {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		hs.logger.DebugContext(r.Context(), "Method not allowed", "method", r.Method, "path", r.URL.Path)
+		if jsonErrorsEnabled(r.Context()) {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+	if hs.jsonErrors {
+		h = jsonErrorsMiddleware(h)
+	}
+
+	return h
+}
+
+// JSONErrors creates an option that makes hook error responses JSON objects of
+// the form {"error": "...", "code": N} instead of plain text. Error detail is
+// included under the same conditions as for plain-text errors. Responses written
+// by HookTimeout are not affected.
+func JSONErrors() Option {
+	return func(hs *HookServer) {
+		hs.jsonErrors = true
+	}
+}
+
+// jsonErrorsKey is the context key marking requests whose errors are written as JSON.
+type jsonErrorsKey struct{}
+
+// jsonErrorsEnabled reports whether errors for the request should be written as JSON.
+func jsonErrorsEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(jsonErrorsKey{}).(bool)
+
+	return enabled
+}
+
+// jsonErrorsMiddleware marks requests to a hook route so writeError responds with JSON.
+func jsonErrorsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jsonErrorsKey{}, true)))
+	})
+}
+
+// writeJSONError writes a JSON error response.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}{Error: msg, Code: code})
+}
+
+// decodeBody decodes the body of a hook request, JSON or a protobuf envelope
+// depending on its Content-Type, into req. On failure it returns the HTTP
+// status code to respond with.
+func decodeBody(r *http.Request, req *rawCompositeRequest) (int, error) {
+	var err error
+	if isProtobufRequest(r) {
+		err = readProtobufRequest(r.Body, req)
+	} else {
+		err = json.NewDecoder(skipBOM(r.Body)).Decode(req)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge, err
+		}
+
+		return http.StatusBadRequest, err
+	}
+
+	return 0, nil
+}
+
+// utf8BOM is the UTF-8 encoding of the byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// skipBOM returns a reader that skips a leading UTF-8 byte order mark in r,
+// which some proxies prepend to request bodies. Whitespace around the JSON
+// document is already ignored by the decoder.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	return br
+}
+
+// decodeHookRequest decodes the body of a hook request and its parent.
+func decodeHookRequest[P client.Object](r *http.Request, decoder runtime.Decoder, scheme *runtime.Scheme, matcher parentMatcher, hook string) *hookRequest {
+	req := &hookRequest{}
+	if code, err := decodeBody(r, &req.raw); err != nil {
+		req.code, req.err = code, fmt.Errorf("%s: error decoding request: %w", hook, err)
+
+		return req
+	}
+
+	parent, code, err := decodeHookParent[P](decoder, scheme, matcher, req.raw.Parent, hook)
+	if err != nil {
+		req.code, req.err = code, err
+
+		return req
+	}
+	req.parent = parent
+
+	return req
+}
+
+// decodeHookParent decodes the parent of a hook request and checks that it
+// belongs to the hook's resource. On failure it returns the HTTP status code to
+// respond with.
+func decodeHookParent[P client.Object](decoder runtime.Decoder, scheme *runtime.Scheme, matcher parentMatcher, data []byte, hook string) (P, int, error) {
+	parent, err := decodeParent[P](decoder, data)
+	if err != nil {
+		return parent, decodeErrorStatus(err), fmt.Errorf("%s: error decoding parent: %w", hook, err)
+	}
+	if err := matcher.check(scheme, parent); err != nil {
+		return parent, http.StatusBadRequest, fmt.Errorf("%s: unexpected parent: %w", hook, err)
+	}
+
+	return parent, 0, nil
+}
+
+// decodeErrorStatus returns the HTTP status code to respond with when an object
+// of a hook request cannot be decoded: 415 Unsupported Media Type for objects
+// sent as protobuf that have no protobuf encoding, 400 Bad Request otherwise.
+func decodeErrorStatus(err error) int {
+	if errors.Is(err, errNoProtobuf) {
+		return http.StatusUnsupportedMediaType
+	}
+
+	return http.StatusBadRequest
+}
+
+// withParentLogger returns r with a request-scoped logger in its context, and
+// the logger itself. The logger is annotated with the hook type and the
+// parent's identity and is available to hooks via composition.LoggerFromContext.
+func withParentLogger(r *http.Request, logger *slog.Logger, hookType string, parent client.Object) (*http.Request, *slog.Logger) {
+	logger = logger.With(
+		"hook", hookType,
+		"parent.namespace", parent.GetNamespace(),
+		"parent.name", parent.GetName(),
+		"parent.uid", string(parent.GetUID()))
+	setAccessLogParent(r.Context(), parent.GetNamespace(), parent.GetName())
+
+	return r.WithContext(composition.WithLogger(r.Context(), logger)), logger
+}
+
+// requestCanceled reports whether the request context was canceled while the
+// hook ran, because Metacontroller disconnected after its own webhook timeout
+// or the HookTimeout elapsed. No response can be delivered in that case, so the
+// hook's result is discarded.
+func requestCanceled(ctx context.Context, logger *slog.Logger, hook string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	logger.WarnContext(ctx, hook+": request canceled before the hook completed; discarding result",
+		"error", context.Cause(ctx).Error())
+
+	return true
+}
+
 // syncHandler handles sync hook HTTP requests.
 type syncHandler[P client.Object] struct {
-	scheme  *runtime.Scheme
-	encoder runtime.Encoder
-	decoder runtime.Decoder
-	syncer  composition.Syncer[P]
-	logger  *slog.Logger
+	scheme          *runtime.Scheme
+	encoder         runtime.Encoder
+	protoEncoder    runtime.Encoder
+	decoder         runtime.Decoder
+	childDecoder    runtime.Decoder
+	childKeys       childKeys
+	syncer          composition.Syncer[P]
+	logger          *slog.Logger
+	strictChildren  bool
+	dedupeChildren  bool
+	childNamespaces *namespaceDefaulter
+	parentMatcher   parentMatcher
+	summaryLogs     bool
+	parentPatcher   client.Client
+	validate        bool
+	crossNamespace  bool
+	explicitPrune   bool
+	mutators        []func(ctx context.Context, parent, child client.Object) error
+}
+
+// newSyncHandler creates a syncHandler for syncer with the server's settings.
+// An empty gvr accepts parents of any resource.
+func newSyncHandler[P client.Object](hs *HookServer, gvr schema.GroupVersionResource, cfg hookConfig, syncer composition.Syncer[P]) *syncHandler[P] {
+	return &syncHandler[P]{
+		scheme:          hs.hookScheme(cfg),
+		decoder:         hs.parentDecoder(cfg),
+		childDecoder:    hs.childDecoder(cfg),
+		childKeys:       hs.childKeys(cfg),
+		encoder:         hs.encoder(cfg),
+		protoEncoder:    hs.protobufEncoder(cfg),
+		syncer:          syncer,
+		logger:          hs.logger,
+		strictChildren:  hs.strictChildren,
+		dedupeChildren:  hs.dedupeChildren,
+		childNamespaces: hs.childNamespaces.withMapper(hs.restMapper),
+		parentMatcher:   parentMatcher{gvr: gvr, mapper: hs.restMapper},
+		summaryLogs:     hs.summaryLogs,
+		parentPatcher:   hs.parentPatcher,
+		validate:        hs.validateResponses,
+		crossNamespace:  hs.crossNamespace,
+		explicitPrune:   hs.explicitPrune,
+		mutators:        hs.childMutators,
+	}
+}
+
+// decodeRequest implements requestDecoder.
+func (sh *syncHandler[P]) decodeRequest(r *http.Request) *hookRequest {
+	return decodeHookRequest[P](r, sh.decoder, sh.scheme, sh.parentMatcher, "SyncHook")
+}
+
+// checkParentType implements parentTypeChecker.
+func (sh *syncHandler[P]) checkParentType() error {
+	return checkParentType[P](sh.scheme, sh.parentMatcher)
+}
+
+// parentType implements parentTyper.
+func (sh *syncHandler[P]) parentType() (schema.GroupVersionKind, reflect.Type, bool) {
+	return parentTypeOf[P](sh.scheme)
 }
 
 // ServeHTTP processes sync hook HTTP requests.
 func (sh *syncHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var rawReq rawCompositeRequest
-	if err := json.NewDecoder(r.Body).Decode(&rawReq); err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("SyncHook: error decoding request: %w", err), sh.logger)
+	req := requestFrom(r, sh)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, sh.logger)
 
 		return
 	}
 
-	p, _, err := sh.decoder.Decode(rawReq.Parent, nil, nil)
-	if err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("SyncHook: error decoding parent: %w", err), sh.logger)
+	sh.serve(w, r, &req.raw, req.parent.(P), schema.GroupVersion{})
+}
 
+// serve processes a decoded sync hook request. If statusVersion is not empty,
+// the returned status is converted to it before encoding.
+func (sh *syncHandler[P]) serve(w http.ResponseWriter, r *http.Request, rawReq *rawCompositeRequest, parent P, statusVersion schema.GroupVersion) {
+	r, logger := withParentLogger(r, sh.logger, HookTypeSync, parent)
+
+	observedChildren, childErrs := decodeChildren(r.Context(), sh.childDecoder, sh.childKeys, rawReq.Children, logger, "SyncHook")
+	related, relatedErrs := decodeChildren(r.Context(), sh.childDecoder, sh.childKeys, rawReq.Related, logger, "SyncHook")
+	if !handleChildErrors(r.Context(), w, append(childErrs, relatedErrs...), sh.strictChildren, logger, "SyncHook") {
 		return
 	}
 
-	parent, ok := p.(P)
-	if !ok {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("SyncHook: type assertion failure: parent"), sh.logger)
-
+	resp, err := sh.syncer.Sync(r.Context(), sh.scheme, &composition.SyncRequest[P]{
+		Controller:        rawReq.Controller,
+		Parent:            parent,
+		Children:          observedChildren,
+		Related:           related,
+		Finalizing:        rawReq.Finalizing,
+		DeletionTimestamp: parent.GetDeletionTimestamp(),
+	})
+	if requestCanceled(r.Context(), logger, "SyncHook") {
 		return
 	}
+	var deferErr *composition.DeferError
+	if errors.As(err, &deferErr) {
+		if len(childErrs) > 0 {
+			writeError(r.Context(), w, http.StatusInternalServerError,
+				fmt.Errorf("SyncHook: cannot defer sync: %d observed children could not be decoded and would be pruned", len(childErrs)), logger)
 
-	observedChildren := make(map[schema.GroupVersionKind][]client.Object)
-	for _, rawList := range rawReq.Children {
-		for _, rawChild := range rawList {
-			childObj, childGVK, err := sh.decoder.Decode(rawChild, nil, nil)
-			if err != nil {
-				sh.logger.ErrorContext(r.Context(),
-					"SyncHook: error decoding child",
-					"error", err.Error(),
-					"child", string(rawChild))
+			return
+		}
+		logger.DebugContext(r.Context(), "SyncHook: sync deferred", "resyncAfter", deferErr.After)
+		children, err := unchangedChildren(observedChildren)
+		if err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: cannot defer sync: %w", err), logger)
 
-				continue
-			}
+			return
+		}
+		sh.write(w, r, compositeResponse{children: children, resyncAfter: deferErr.After}, logger)
 
-			child, ok := childObj.(client.Object)
-			if !ok {
-				sh.logger.ErrorContext(r.Context(),
-					"SyncHook: type assertion failure: child is not a client.Object",
-					"child",
-					string(rawChild))
+		return
+	}
+	if err != nil {
+		writeError(r.Context(), w, composition.HTTPStatus(err), fmt.Errorf("SyncHook: handler error: %w", err), logger)
 
-				continue
-			}
-			observedChildren[*childGVK] = append(observedChildren[*childGVK], child)
+		return
+	}
+
+	if sh.validate {
+		if err := resp.Validate(sh.scheme); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: invalid response: %w", err), logger)
+
+			return
 		}
 	}
 
-	resp, err := sh.syncer.Sync(r.Context(), sh.scheme, &composition.SyncRequest[P]{
-		Parent:   parent,
-		Children: observedChildren,
-	})
-	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: handler error: %w", err), sh.logger)
+	children := resp.Children
+	if sh.childNamespaces != nil {
+		if err := sh.childNamespaces.apply(sh.scheme, parent, children); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: error defaulting child namespaces: %w", err), logger)
+
+			return
+		}
+	}
+	if err := mutateChildren(r.Context(), sh.mutators, parent, children); err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: %w", err), logger)
 
 		return
 	}
+	if !sh.crossNamespace {
+		if err := checkChildNamespaces(parent, children); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: %w", err), logger)
 
-	statusBytes, err := runtime.Encode(sh.encoder, resp.Status)
-	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: error encoding status: %w", err), sh.logger)
+			return
+		}
+	}
+	if err := validateChildKinds(sh.scheme, children); err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: invalid desired children: %w", err), logger)
 
 		return
 	}
+	if sh.dedupeChildren {
+		if children, err = dedupeChildren(r.Context(), sh.scheme, children, logger); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: %w", err), logger)
 
-	desiredChildren := make([]json.RawMessage, len(resp.Children))
-	for i, child := range resp.Children {
-		encodedChild, err := runtime.Encode(sh.encoder, child)
-		if err != nil {
-			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: error encoding child: %w", err), sh.logger)
+			return
+		}
+	}
+	if !rawReq.Finalizing {
+		if !sh.checkPrune(w, r, observedChildren, children, resp, logger) {
+			return
+		}
+	}
+
+	if sh.parentPatcher != nil {
+		if err := composition.PatchParentMetadata(r.Context(), sh.parentPatcher, parent, resp.ParentMetadata); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: %w", err), logger)
 
 			return
 		}
+	} else if !resp.ParentMetadata.IsEmpty() {
+		logger.WarnContext(r.Context(), "SyncHook: ignoring ParentMetadata; configure the ParentPatcher option to apply it")
+	}
+
+	var status runtime.Object = resp.Status
+	if !statusVersion.Empty() && !isNilObject(status) {
+		if status, err = sh.scheme.ConvertToVersion(status, statusVersion); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("SyncHook: error converting status to %s: %w", statusVersion, err), logger)
 
-		desiredChildren[i] = json.RawMessage(encodedChild)
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(rawCompositeResponse{
-		Status:   statusBytes,
-		Children: desiredChildren,
-	}); err != nil {
-		sh.logger.ErrorContext(r.Context(), "SyncHook: error encoding response: "+err.Error())
+	if !sh.write(w, r, compositeResponse{
+		status:       status,
+		children:     children,
+		childPatches: resp.ChildPatches,
+		finalized:    resp.Finalized,
+		resyncAfter:  resp.ResyncAfter,
+	}, logger) {
+		return
 	}
-}
 
-type customizeHandler[P client.Object] struct {
-	scheme     *runtime.Scheme
-	decoder    runtime.Decoder
-	customizer composition.Customizer[P]
-	logger     *slog.Logger
+	level := slog.LevelDebug
+	if sh.summaryLogs {
+		level = slog.LevelInfo
+	}
+	if logger.Enabled(r.Context(), level) {
+		logger.Log(r.Context(), level, "SyncHook: synced parent",
+			"observedChildren", countObserved(sh.childKeys, observedChildren),
+			"desiredChildren", countDesired(sh.scheme, sh.childKeys, children),
+			"finalized", resp.Finalized)
+	}
 }
 
-// ServeHTTP processes customize hook HTTP requests.
-func (ch *customizeHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var rawReq rawCustomizeRequest
-	if err := json.NewDecoder(r.Body).Decode(&rawReq); err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("CustomizeHook: error decoding request: %w", err), ch.logger)
-		return
+// checkPrune warns about, or with RequireExplicitPrune rejects, a response that
+// returns no children of an observed kind without marking it in resp.Prune. It
+// reports whether the response may be written.
+func (sh *syncHandler[P]) checkPrune(w http.ResponseWriter, r *http.Request, observed map[schema.GroupVersionKind][]client.Object, children []client.Object, resp *composition.SyncResponse[P], logger *slog.Logger) bool {
+	kinds := unprunedKinds(sh.scheme, observed, children, resp.Prune)
+	if len(kinds) == 0 {
+		return true
 	}
 
-	p, _, err := ch.decoder.Decode(rawReq.Parent, nil, nil)
+	keys := make([]string, len(kinds))
+	for i, gvk := range kinds {
+		keys[i] = sh.childKeys.key(gvk)
+	}
+	if sh.explicitPrune {
+		writeError(r.Context(), w, http.StatusInternalServerError,
+			fmt.Errorf("SyncHook: response deletes every child of %s without marking the kinds in SyncResponse.Prune", strings.Join(keys, ", ")), logger)
+
+		return false
+	}
+	logger.WarnContext(r.Context(), "SyncHook: response deletes every observed child of some kinds; set SyncResponse.Prune if intended", "kinds", keys)
+
+	return true
+}
+
+// write encodes and writes a sync response. It reports whether the response
+// was written successfully.
+func (sh *syncHandler[P]) write(w http.ResponseWriter, r *http.Request, resp compositeResponse, logger *slog.Logger) bool {
+	return writeComposite(w, r, resp, sh.encoder, sh.protoEncoder, logger, "SyncHook")
+}
+
+// writeComposite encodes and writes a sync or finalize response, as protobuf if
+// negotiateProtobuf selects it and the hook has a protoEncoder, and as JSON
+// otherwise. A response with objects that have no protobuf encoding falls back
+// to JSON if the request accepts it, and fails with 406 Not Acceptable if not.
+// It reports whether the response was written successfully.
+func writeComposite(w http.ResponseWriter, r *http.Request, resp compositeResponse, encoder, protoEncoder runtime.Encoder, logger *slog.Logger, hook string) bool {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	contentType := runtime.ContentTypeJSON
+	var err error
+	if wantProtobuf, jsonOK := negotiateProtobuf(r); wantProtobuf && protoEncoder != nil {
+		contentType = runtime.ContentTypeProtobuf
+		err = resp.encodeProtobuf(buf, protoEncoder)
+		if errors.Is(err, errNoProtobuf) {
+			if !jsonOK {
+				writeError(r.Context(), w, http.StatusNotAcceptable, fmt.Errorf("%s: %w", hook, err), logger)
+
+				return false
+			}
+			logger.DebugContext(r.Context(), hook+": falling back to JSON response", "error", err.Error())
+			buf.Reset()
+			contentType = runtime.ContentTypeJSON
+			err = resp.encode(buf, encoder)
+		}
+	} else {
+		err = resp.encode(buf, encoder)
+	}
 	if err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("CustomizeHook: error decoding parent: %w", err), ch.logger)
-		return
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("%s: %w", hook, err), logger)
+
+		return false
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if _, err := buf.WriteTo(w); err != nil {
+		logger.ErrorContext(r.Context(), hook+": error writing response: "+err.Error())
+
+		return false
 	}
 
-	parent, ok := p.(P)
-	if !ok {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("CustomizeHook: type assertion failure for parent"), ch.logger)
+	return true
+}
+
+type customizeHandler[P client.Object] struct {
+	scheme        *runtime.Scheme
+	decoder       runtime.Decoder
+	customizer    composition.Customizer[P]
+	logger        *slog.Logger
+	parentMatcher parentMatcher
+	validate      bool
+}
+
+// decodeRequest implements requestDecoder.
+func (ch *customizeHandler[P]) decodeRequest(r *http.Request) *hookRequest {
+	return decodeHookRequest[P](r, ch.decoder, ch.scheme, ch.parentMatcher, "CustomizeHook")
+}
+
+// checkParentType implements parentTypeChecker.
+func (ch *customizeHandler[P]) checkParentType() error {
+	return checkParentType[P](ch.scheme, ch.parentMatcher)
+}
+
+// parentType implements parentTyper.
+func (ch *customizeHandler[P]) parentType() (schema.GroupVersionKind, reflect.Type, bool) {
+	return parentTypeOf[P](ch.scheme)
+}
+
+// ServeHTTP processes customize hook HTTP requests.
+func (ch *customizeHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := requestFrom(r, ch)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, ch.logger)
 		return
 	}
+	rawReq, parent := req.raw, req.parent.(P)
+
+	r, logger := withParentLogger(r, ch.logger, HookTypeCustomize, parent)
 
 	resp, err := ch.customizer.Customize(r.Context(), ch.scheme, &composition.CustomizeRequest[P]{
 		Controller: rawReq.Controller,
 		Parent:     parent,
 	})
+	if requestCanceled(r.Context(), logger, "CustomizeHook") {
+		return
+	}
 	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("CustomizeHook: CustomizeHandler failed with error: %w", err), ch.logger)
+		writeError(r.Context(), w, composition.HTTPStatus(err), fmt.Errorf("CustomizeHook: CustomizeHandler failed with error: %w", err), logger)
 		return
 	}
+	if ch.validate {
+		if err := resp.Validate(); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("CustomizeHook: invalid response: %w", err), logger)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		ch.logger.Error("CustomizeHook: error encoding response", "error", err.Error())
+		logger.Error("CustomizeHook: error encoding response", "error", err.Error())
 	}
 }
 
 type finalizeHandler[P client.Object] struct {
-	scheme    *runtime.Scheme
-	encoder   runtime.Encoder
-	decoder   runtime.Decoder
-	finalizer composition.Finalizer[P]
-	logger    *slog.Logger
+	scheme          *runtime.Scheme
+	encoder         runtime.Encoder
+	protoEncoder    runtime.Encoder
+	decoder         runtime.Decoder
+	childDecoder    runtime.Decoder
+	childKeys       childKeys
+	finalizer       composition.Finalizer[P]
+	logger          *slog.Logger
+	strictChildren  bool
+	parentMatcher   parentMatcher
+	validate        bool
+	childNamespaces *namespaceDefaulter
+	crossNamespace  bool
+	dedupeChildren  bool
+	mutators        []func(ctx context.Context, parent, child client.Object) error
+}
+
+// decodeRequest implements requestDecoder.
+func (fh *finalizeHandler[P]) decodeRequest(r *http.Request) *hookRequest {
+	return decodeHookRequest[P](r, fh.decoder, fh.scheme, fh.parentMatcher, "FinalizeHook")
+}
+
+// checkParentType implements parentTypeChecker.
+func (fh *finalizeHandler[P]) checkParentType() error {
+	return checkParentType[P](fh.scheme, fh.parentMatcher)
+}
+
+// parentType implements parentTyper.
+func (fh *finalizeHandler[P]) parentType() (schema.GroupVersionKind, reflect.Type, bool) {
+	return parentTypeOf[P](fh.scheme)
 }
 
 // ServeHTTP processes finalize hook HTTP requests.
 func (fh *finalizeHandler[P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var rawReq rawCompositeRequest
-	if err := json.NewDecoder(r.Body).Decode(&rawReq); err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("FinalizeHook: error decoding request: %w", err), fh.logger)
+	req := requestFrom(r, fh)
+	if req.err != nil {
+		writeError(r.Context(), w, req.code, req.err, fh.logger)
 		return
 	}
+	rawReq, parent := req.raw, req.parent.(P)
 
-	p, _, err := fh.decoder.Decode(rawReq.Parent, nil, nil)
-	if err != nil {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("FinalizeHook: error decoding parent: %w", err), fh.logger)
+	r, logger := withParentLogger(r, fh.logger, HookTypeFinalize, parent)
+
+	observedChildren, childErrs := decodeChildren(r.Context(), fh.childDecoder, fh.childKeys, rawReq.Children, logger, "FinalizeHook")
+	related, relatedErrs := decodeChildren(r.Context(), fh.childDecoder, fh.childKeys, rawReq.Related, logger, "FinalizeHook")
+	if !handleChildErrors(r.Context(), w, append(childErrs, relatedErrs...), fh.strictChildren, logger, "FinalizeHook") {
 		return
 	}
 
-	parent, ok := p.(P)
-	if !ok {
-		writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("FinalizeHook: type assertion failure for parent"), fh.logger)
+	resp, err := fh.finalizer.Finalize(r.Context(), fh.scheme, &composition.FinalizeRequest[P]{
+		Controller: rawReq.Controller,
+		Parent:     parent,
+		Children:   observedChildren,
+		Related:    related,
+	})
+	if requestCanceled(r.Context(), logger, "FinalizeHook") {
+		return
+	}
+	if err != nil {
+		writeError(r.Context(), w, composition.HTTPStatus(err),
+			fmt.Errorf("FinalizeHook: FinalizeHandler failed with error: %w", err),
+			logger)
 		return
 	}
 
-	observedChildren := make(map[schema.GroupVersionKind][]client.Object)
-	for _, rawList := range rawReq.Children {
-		for _, rawChild := range rawList {
-			childObj, childGVK, err := fh.decoder.Decode(rawChild, nil, nil)
-			if err != nil {
-				fh.logger.ErrorContext(r.Context(),
-					"Finalize error: unable to decoding child",
-					"error", err.Error(),
-					"child", string(rawChild))
+	if fh.validate {
+		if err := resp.Validate(fh.scheme); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: invalid response: %w", err), logger)
 
-				continue
-			}
+			return
+		}
+	}
 
-			child, ok := childObj.(client.Object)
-			if !ok {
-				fh.logger.ErrorContext(r.Context(),
-					"Finalize error: child is not a client.Object",
-					"child",
-					string(rawChild))
+	children := flattenChildren(resp.Children)
+	if fh.childNamespaces != nil {
+		if err := fh.childNamespaces.apply(fh.scheme, parent, children); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: error defaulting child namespaces: %w", err), logger)
 
-				continue
-			}
-			observedChildren[*childGVK] = append(observedChildren[*childGVK], child)
+			return
 		}
 	}
+	if err := mutateChildren(r.Context(), fh.mutators, parent, children); err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: %w", err), logger)
 
-	resp, err := fh.finalizer.Finalize(r.Context(), fh.scheme, &composition.FinalizeRequest[P]{
-		Parent: parent,
-	})
-	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError,
-			fmt.Errorf("FinalizeHook: FinalizeHandler failed with error: %w", err),
-			fh.logger)
 		return
 	}
+	if !fh.crossNamespace {
+		if err := checkChildNamespaces(parent, children); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: %w", err), logger)
 
-	statusBytes, err := runtime.Encode(fh.encoder, resp.Status)
-	if err != nil {
-		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("finalize failed: error encoding parent status: %w", err), fh.logger)
+			return
+		}
+	}
+	if err := validateChildKinds(fh.scheme, children); err != nil {
+		writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: invalid desired children: %w", err), logger)
 
 		return
 	}
+	if fh.dedupeChildren {
+		if children, err = dedupeChildren(r.Context(), fh.scheme, children, logger); err != nil {
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("FinalizeHook: %w", err), logger)
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(rawCompositeResponse{
-		Status:    statusBytes,
-		Finalized: resp.Finalized,
-	}); err != nil {
-		fh.logger.Error("Finalize error: unable to encode response", "error", err.Error())
+			return
+		}
 	}
+
+	writeComposite(w, r, compositeResponse{status: resp.Status, children: children, finalized: resp.Finalized}, fh.encoder, fh.protoEncoder, logger, "FinalizeHook")
 }
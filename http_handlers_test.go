@@ -0,0 +1,67 @@
+package metacontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+func TestSyncHookFinalized(t *testing.T) {
+	hs := newSyncServer(t, syncFunc(func(_ context.Context, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return &composition.SyncResponse[*corev1.ConfigMap]{Finalized: req.Finalizing}, nil
+	}))
+
+	for _, finalizing := range []bool{false, true} {
+		body := `{"parent":` + parentJSON + `,"finalizing":` + strconv.FormatBool(finalizing) + `}`
+		w := postSync(hs, body, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("finalizing=%v: status = %d, want %d: %s", finalizing, w.Code, http.StatusOK, w.Body)
+		}
+		var resp struct {
+			Finalized bool `json:"finalized"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Finalized != finalizing {
+			t.Errorf("finalizing=%v: finalized = %v, want %v", finalizing, resp.Finalized, finalizing)
+		}
+	}
+}
+
+func TestSyncHookCanceledRequest(t *testing.T) {
+	started := make(chan struct{})
+	var syncErr error
+	hs := newSyncServer(t, syncFunc(func(ctx context.Context, _ *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		close(started)
+		<-ctx.Done()
+		syncErr = ctx.Err()
+
+		return &composition.SyncResponse[*corev1.ConfigMap]{}, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequestWithContext(ctx, http.MethodPost, hs.HookPath(HookTypeSync, configMaps), strings.NewReader(`{"parent":`+parentJSON+`}`))
+	w := httptest.NewRecorder()
+	go func() {
+		<-started
+		cancel()
+	}()
+	hs.ServeHTTP(w, r)
+
+	if !errors.Is(syncErr, context.Canceled) {
+		t.Errorf("Syncer saw ctx.Err() = %v, want %v", syncErr, context.Canceled)
+	}
+	if w.Body.Len() > 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("response written for a canceled request: %d %q", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,70 @@
+package metacontroller
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// CompositeControllerManifest returns the CompositeController resource named
+// name that describes the registered hooks: its parent resource and the path
+// of each hook. The webhooks carry only their paths, so set their url or
+// service before applying the manifest, along with any childResources, which
+// cannot be inferred from the hooks. It returns an error unless the hooks with
+// a parent resource (all but DispatchSyncHook) serve exactly one.
+func (hs *HookServer) CompositeControllerManifest(name string) (*unstructured.Unstructured, error) {
+	var (
+		parent schema.GroupVersionResource
+		hooks  composition.CompositeControllerHooks
+	)
+	for _, route := range hs.Routes() {
+		if route.GVR.Empty() {
+			continue
+		}
+		if !parent.Empty() && route.GVR != parent {
+			return nil, fmt.Errorf("hooks are registered for several parent resources: %s and %s", parent, route.GVR)
+		}
+		parent = route.GVR
+
+		hook := &composition.Hook{Webhook: &composition.Webhook{Path: &route.Path}}
+		switch route.HookType {
+		case HookTypeSync:
+			hooks.Sync = hook
+		case HookTypeFinalize:
+			hooks.Finalize = hook
+		case HookTypeCustomize:
+			hooks.Customize = hook
+		}
+	}
+	if parent.Empty() {
+		return nil, errors.New("no hooks are registered for a parent resource")
+	}
+
+	cc := &composition.CompositeController{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "metacontroller.k8s.io/v1alpha1",
+			Kind:       "CompositeController",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: composition.CompositeControllerSpec{
+			ParentResource: composition.ParentResourceRule{
+				APIVersion: parent.GroupVersion().String(),
+				Resource:   parent.Resource,
+			},
+			Hooks: &hooks,
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cc)
+	if err != nil {
+		return nil, fmt.Errorf("error converting CompositeController: %w", err)
+	}
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+
+	return &unstructured.Unstructured{Object: obj}, nil
+}
@@ -0,0 +1,142 @@
+package metacontrollertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// DefaultMaxIterations is the default number of sync calls a FakeController
+// makes before giving up on convergence.
+const DefaultMaxIterations = 10
+
+// FakeController is an in-memory stand-in for Metacontroller that drives a sync
+// hook to a steady state. Each iteration sends the parent and the children in
+// its store to the hook, applies the returned status to the parent, and
+// replaces the store with the desired children, deleting those no longer
+// desired. Desired children without a namespace are created in the parent's
+// namespace. It does not simulate the API server: children are stored exactly
+// as returned, without defaulting or status.
+type FakeController[P client.Object] struct {
+	// MaxIterations is the number of sync calls Reconcile makes before failing
+	// the test. (Default: DefaultMaxIterations)
+	MaxIterations int
+
+	t        testing.TB
+	handler  http.Handler
+	path     string
+	scheme   *runtime.Scheme
+	parent   P
+	children []client.Object
+}
+
+// NewFakeController returns a FakeController that sends sync requests for
+// parent to the hook served by handler at path, starting from the observed
+// children.
+func NewFakeController[P client.Object](t testing.TB, handler http.Handler, path string, scheme *runtime.Scheme, parent P, children ...client.Object) *FakeController[P] {
+	return &FakeController[P]{
+		MaxIterations: DefaultMaxIterations,
+		t:             t,
+		handler:       handler,
+		path:          path,
+		scheme:        scheme,
+		parent:        parent,
+		children:      children,
+	}
+}
+
+// Reconcile calls the sync hook until two consecutive calls return the same
+// status and children, and returns the number of calls made. It fails the test
+// if the hook fails or does not converge within MaxIterations calls.
+func (fc *FakeController[P]) Reconcile() int {
+	fc.t.Helper()
+
+	var last []byte
+	for i := 1; i <= fc.MaxIterations; i++ {
+		result := PostSync[P](fc.t, fc.handler, fc.path, fc.scheme, NewSyncRequest(fc.parent, fc.children...))
+		fc.apply(result)
+
+		state := fc.snapshot()
+		if last != nil && bytes.Equal(state, last) {
+			return i
+		}
+		last = state
+	}
+	fc.t.Fatalf("metacontrollertest: sync hook did not converge within %d iterations", fc.MaxIterations)
+
+	return fc.MaxIterations
+}
+
+// Parent returns the parent, with the status most recently returned by the hook.
+func (fc *FakeController[P]) Parent() P {
+	return fc.parent
+}
+
+// Children returns the children in the store, sorted by kind, namespace, and
+// name.
+func (fc *FakeController[P]) Children() []client.Object {
+	return slices.Clone(fc.children)
+}
+
+// apply applies a sync result to the parent and the store.
+func (fc *FakeController[P]) apply(result *SyncResult[P]) {
+	fc.t.Helper()
+
+	if status := reflect.ValueOf(result.Status); status.IsValid() && !status.IsNil() {
+		fc.parent = result.Status
+	}
+
+	children := make(map[string]client.Object, len(result.Children))
+	for _, child := range result.Children {
+		if ns := fc.parent.GetNamespace(); ns != "" {
+			if err := composition.DefaultNamespace(fc.scheme, nil, []client.Object{child}, ns); err != nil {
+				fc.t.Fatalf("metacontrollertest: %v", err)
+			}
+		}
+		children[fc.childKey(child)] = child
+	}
+
+	keys := make([]string, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	fc.children = fc.children[:0:0]
+	for _, key := range keys {
+		fc.children = append(fc.children, children[key])
+	}
+}
+
+// snapshot encodes the parent and the store, for comparison between iterations.
+func (fc *FakeController[P]) snapshot() []byte {
+	fc.t.Helper()
+
+	data, err := json.Marshal(append([]client.Object{fc.parent}, fc.children...))
+	if err != nil {
+		fc.t.Fatalf("metacontrollertest: %v", err)
+	}
+
+	return data
+}
+
+// childKey returns the key that identifies child in the store.
+func (fc *FakeController[P]) childKey(child client.Object) string {
+	fc.t.Helper()
+
+	gvk, err := apiutil.GVKForObject(child, fc.scheme)
+	if err != nil {
+		fc.t.Fatalf("metacontrollertest: error resolving kind of child %s: %v", client.ObjectKeyFromObject(child), err)
+	}
+
+	return composition.KeyForGVK(gvk) + " " + client.ObjectKeyFromObject(child).String()
+}
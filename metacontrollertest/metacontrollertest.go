@@ -0,0 +1,129 @@
+// Package metacontrollertest provides helpers for testing Metacontroller hooks
+// in-process. It builds hook requests in Metacontroller's wire format, serves
+// them through a HookServer with net/http/httptest, and decodes the responses
+// back into typed objects, so tests can assert on desired children without
+// touching HTTP plumbing.
+package metacontrollertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/a2y-d5l/go-metacontroller"
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// SyncRequest describes a sync hook request to send to a hook.
+type SyncRequest struct {
+	// Parent is the parent resource.
+	Parent client.Object
+	// Children are the observed children of the parent.
+	Children []client.Object
+	// Finalizing indicates that the parent is being deleted.
+	Finalizing bool
+}
+
+// NewSyncRequest returns a SyncRequest for parent and its observed children.
+func NewSyncRequest(parent client.Object, children ...client.Object) *SyncRequest {
+	return &SyncRequest{Parent: parent, Children: children}
+}
+
+// Marshal encodes the request in Metacontroller's wire format with
+// composition.EncodeSyncRequest. Objects without TypeMeta have their apiVersion
+// and kind resolved from the scheme.
+func (req *SyncRequest) Marshal(scheme *runtime.Scheme) ([]byte, error) {
+	children := make(map[schema.GroupVersionKind][]client.Object)
+	for _, child := range req.Children {
+		gvk, err := apiutil.GVKForObject(child, scheme)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving kind of child %s: %w", client.ObjectKeyFromObject(child), err)
+		}
+		children[gvk] = append(children[gvk], child)
+	}
+
+	return composition.EncodeSyncRequest(scheme, &composition.SyncRequest[client.Object]{
+		Parent:     req.Parent,
+		Children:   children,
+		Finalizing: req.Finalizing,
+	})
+}
+
+// SyncResult is a decoded sync hook response.
+type SyncResult[P client.Object] struct {
+	// Status is the parent status returned by the hook.
+	Status P
+	// Children are the desired children returned by the hook, decoded into
+	// their typed representation when registered in the scheme.
+	Children []client.Object
+	// ChildPatches are the child patches returned by the hook.
+	ChildPatches []composition.ChildPatch
+	// Finalized reports whether the hook marked the parent as finalized.
+	Finalized bool
+	// ResyncAfter is the delay after which the hook asked to be called again.
+	ResyncAfter time.Duration
+}
+
+// InvokeSync registers syncer as the sync hook of a new HookServer, sends req to
+// it, and decodes the response. The hook is registered for the resource guessed
+// from the parent's kind, and opts configure the HookServer. InvokeSync fails
+// the test if the hook does not respond 200 OK or the response cannot be decoded.
+func InvokeSync[P client.Object](t testing.TB, scheme *runtime.Scheme, syncer composition.Syncer[P], req *SyncRequest, opts ...metacontroller.Option) *SyncResult[P] {
+	t.Helper()
+
+	gvk, err := apiutil.GVKForObject(req.Parent, scheme)
+	if err != nil {
+		t.Fatalf("metacontrollertest: error resolving kind of parent: %v", err)
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+
+	opts = append([]metacontroller.Option{
+		metacontroller.Logger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	opts = append(opts, metacontroller.CompositeController(metacontroller.SyncHook(gvr, syncer)))
+	hs := metacontroller.NewHookServer(scheme, opts...)
+
+	return PostSync[P](t, hs, hs.HookPath(metacontroller.HookTypeSync, gvr), scheme, req)
+}
+
+// PostSync sends req to the sync hook served by handler at path and decodes the
+// response. It fails the test if the hook does not respond 200 OK or the
+// response cannot be decoded.
+func PostSync[P client.Object](t testing.TB, handler http.Handler, path string, scheme *runtime.Scheme, req *SyncRequest) *SyncResult[P] {
+	t.Helper()
+
+	body, err := req.Marshal(scheme)
+	if err != nil {
+		t.Fatalf("metacontrollertest: error encoding sync request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("metacontrollertest: sync hook responded %d: %s", w.Code, w.Body.String())
+	}
+
+	resp, err := composition.DecodeSyncResponse[P](scheme, w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("metacontrollertest: error decoding sync response: %v", err)
+	}
+
+	return &SyncResult[P]{
+		Status:       resp.Status,
+		Children:     resp.Children,
+		ChildPatches: resp.ChildPatches,
+		Finalized:    resp.Finalized,
+		ResyncAfter:  resp.ResyncAfter,
+	}
+}
@@ -0,0 +1,47 @@
+package metacontrollertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+func TestInvokeSync(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	syncer := composition.SyncerFunc[*corev1.ConfigMap](func(_ context.Context, _ *runtime.Scheme, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		status := req.Parent.DeepCopy()
+		status.Data = map[string]string{"children": "1"}
+
+		return &composition.SyncResponse[*corev1.ConfigMap]{
+			Status:      status,
+			Children:    req.Children[corev1.SchemeGroupVersion.WithKind("Secret")],
+			ResyncAfter: time.Minute,
+		}, nil
+	})
+	parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "default"}}
+	child := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "default"}}
+
+	result := InvokeSync[*corev1.ConfigMap](t, scheme, syncer, NewSyncRequest(parent, child))
+	if result.Status == nil || result.Status.Data["children"] != "1" {
+		t.Errorf("Status = %v, want the parent with children=1", result.Status)
+	}
+	if len(result.Children) != 1 || client.ObjectKeyFromObject(result.Children[0]).String() != "default/s" {
+		t.Fatalf("Children = %v, want the observed Secret default/s", result.Children)
+	}
+	if _, ok := result.Children[0].(*corev1.Secret); !ok {
+		t.Errorf("Children[0] is %T, want *corev1.Secret", result.Children[0])
+	}
+	if result.ResyncAfter != time.Minute {
+		t.Errorf("ResyncAfter = %v, want %v", result.ResyncAfter, time.Minute)
+	}
+}
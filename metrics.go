@@ -0,0 +1,188 @@
+package metacontroller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// MetricsRecorder records per-hook request metrics. It keeps the HookServer
+// independent of any particular metrics library; a Prometheus implementation
+// typically wraps a duration histogram and an outcome counter:
+//
+//	type promRecorder struct {
+//		duration *prometheus.HistogramVec // labels: hook, resource
+//		requests *prometheus.CounterVec   // labels: hook, resource, code
+//	}
+//
+//	func (p *promRecorder) ObserveHook(ctx context.Context, hookType, resource string, code int, d time.Duration) {
+//		p.duration.WithLabelValues(hookType, resource).Observe(d.Seconds())
+//		p.requests.WithLabelValues(hookType, resource, strconv.Itoa(code)).Inc()
+//	}
+type MetricsRecorder interface {
+	// ObserveHook records a completed hook request. hookType is one of the
+	// HookType constants, resource is the parent resource in
+	// "<group.resource>/<version>" form, and code is the HTTP status written.
+	ObserveHook(ctx context.Context, hookType, resource string, code int, duration time.Duration)
+}
+
+// Metrics creates an option that records metrics for every registered hook with
+// the given recorder. If handler is non-nil it is served at "/metrics"
+// (e.g. promhttp.HandlerFor(registry, promhttp.HandlerOpts{})); the metrics
+// endpoint is not itself a hook route and is not measured.
+func Metrics(recorder MetricsRecorder, handler http.Handler) Option {
+	return func(hs *HookServer) {
+		hs.metrics = recorder
+		if handler != nil {
+			hs.mux.Handle("GET /metrics", handler)
+		}
+	}
+}
+
+// ExemplarRecorder is optionally implemented by a MetricsRecorder to attach the
+// trace ID of each hook request as an exemplar, e.g. to jump from a slow
+// latency bucket to its trace. It is called in place of ObserveHook when the
+// Tracing option is set and the request's Span implements TraceIDSpan with a
+// non-empty trace ID:
+//
+//	func (p *promRecorder) ObserveHookWithTraceID(ctx context.Context, hookType, resource string, code int, d time.Duration, traceID string) {
+//		p.duration.WithLabelValues(hookType, resource).(prometheus.ExemplarObserver).
+//			ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+//		p.requests.WithLabelValues(hookType, resource, strconv.Itoa(code)).Inc()
+//	}
+//
+// Exemplars are only exposed when the metrics handler serves OpenMetrics, e.g.
+// with promhttp.HandlerOpts{EnableOpenMetrics: true}.
+type ExemplarRecorder interface {
+	// ObserveHookWithTraceID records a completed hook request like
+	// ObserveHook, along with the ID of the trace it was served in.
+	ObserveHookWithTraceID(ctx context.Context, hookType, resource string, code int, duration time.Duration, traceID string)
+}
+
+// metricsMiddleware records the duration and outcome of each request to a hook
+// route. It runs within tracingMiddleware, so the request context carries the
+// span whose trace ID is passed to an ExemplarRecorder.
+func metricsMiddleware(recorder MetricsRecorder, rt hookRoute, next http.Handler) http.Handler {
+	resource := rt.resource()
+	exemplars, _ := recorder.(ExemplarRecorder)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		d := time.Since(start)
+		if exemplars != nil {
+			if traceID := traceIDFromContext(r.Context()); traceID != "" {
+				exemplars.ObserveHookWithTraceID(r.Context(), rt.hookType, resource, rec.Status(), d, traceID)
+
+				return
+			}
+		}
+		recorder.ObserveHook(r.Context(), rt.hookType, resource, rec.Status(), d)
+	})
+}
+
+// LastSyncRecorder is optionally implemented by a MetricsRecorder to observe
+// the time of the last successful sync of each parent resource, e.g. as a
+// gauge that alerts can compare with the current time to catch stuck
+// controllers.
+type LastSyncRecorder interface {
+	// ObserveLastSync records that a sync hook for resource, in the form used
+	// by ObserveHook, responded successfully at t.
+	ObserveLastSync(ctx context.Context, resource string, t time.Time)
+}
+
+// LastSync returns the time the sync hook for the parent resource identified by
+// gvr last responded successfully, or the zero time if it has not. Use an empty
+// gvr for a DispatchSyncHook.
+func (hs *HookServer) LastSync(gvr schema.GroupVersionResource) time.Time {
+	hs.lastSyncMu.Lock()
+	defer hs.lastSyncMu.Unlock()
+
+	return hs.lastSync[gvr]
+}
+
+// lastSyncMiddleware records the time of each successful request to a sync
+// hook route.
+func (hs *HookServer) lastSyncMiddleware(rt hookRoute, next http.Handler) http.Handler {
+	resource := rt.resource()
+	recorder, _ := hs.metrics.(LastSyncRecorder)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		// A request that wrote nothing, e.g. because it was canceled, is not
+		// a successful sync.
+		if rec.status != http.StatusOK {
+			return
+		}
+
+		now := time.Now()
+		hs.lastSyncMu.Lock()
+		hs.lastSync[rt.gvr] = now
+		hs.lastSyncMu.Unlock()
+		if recorder != nil {
+			recorder.ObserveLastSync(r.Context(), resource, now)
+		}
+	})
+}
+
+// UnknownFieldRecorder is optionally implemented by a MetricsRecorder to count
+// the fields that parents were rejected for under StrictDecoding, e.g. as a
+// decode_unknown_field_total counter labeled by field, to spot clients sending
+// specs that have drifted from the Go types.
+type UnknownFieldRecorder interface {
+	// ObserveUnknownField records that a parent sent to a hook for resource,
+	// in the form used by ObserveHook, had the unknown field at path field
+	// (e.g. "spec.replicaCount"). Array indexes in field are replaced with
+	// "[*]" to bound the number of distinct paths.
+	ObserveUnknownField(ctx context.Context, hookType, resource, field string)
+}
+
+// unknownFieldsMiddleware records the unknown fields of parents that failed
+// strict decoding in decodeMiddleware.
+func unknownFieldsMiddleware(recorder UnknownFieldRecorder, rt hookRoute, next http.Handler) http.Handler {
+	resource := rt.resource()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := r.Context().Value(hookRequestKey{}).(*hookRequest); ok && req.err != nil {
+			for _, field := range unknownFields(req.err) {
+				recorder.ObserveUnknownField(r.Context(), rt.hookType, resource, field)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// arrayIndex matches the array indexes in a strict decoding field path.
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// unknownFields returns the paths of the unknown fields reported by a strict
+// decoding error in err's chain.
+func unknownFields(err error) []string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		strictErr, ok := runtime.AsStrictDecodingError(err)
+		if !ok {
+			continue
+		}
+
+		var fields []string
+		for _, fieldErr := range strictErr.Errors() {
+			pathErr, ok := fieldErr.(interface{ FieldPath() string })
+			if !ok || !strings.HasPrefix(fieldErr.Error(), "unknown field") {
+				continue
+			}
+			fields = append(fields, arrayIndex.ReplaceAllString(pathErr.FieldPath(), "[*]"))
+		}
+
+		return fields
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package metacontroller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Use creates an option that wraps every registered hook handler with the given
+// middleware. Middleware apply in the order given, so the first one sees the
+// request first. They run inside the server's built-in handling
+// (authentication, concurrency limits, timeouts, panic recovery, and metrics),
+// after the request body has been decoded, so middleware can inspect the
+// parent with composition.ParentFromContext but cannot rewrite the body. They
+// apply to every hook regardless of whether Use appears before or after the
+// hook options, but not to health, readiness, or metrics endpoints. Use may be
+// given more than once; later middleware run inside earlier ones.
+func Use(mw ...func(http.Handler) http.Handler) Option {
+	return func(hs *HookServer) {
+		hs.middleware = append(hs.middleware, mw...)
+	}
+}
+
+// wrapHook wraps a hook handler with the middleware configured on the HookServer.
+func (hs *HookServer) wrapHook(rt hookRoute, h http.Handler) http.Handler {
+	decoder, decodes := h.(requestDecoder)
+	if hs.syncCache != nil && rt.hookType == HookTypeSync && decodes {
+		h = hs.cacheMiddleware(hs.syncCache, rt, h)
+	}
+	for i := len(hs.middleware) - 1; i >= 0; i-- {
+		h = hs.middleware[i](h)
+	}
+	if recorder, ok := hs.metrics.(UnknownFieldRecorder); ok && hs.strictDecoding && decodes {
+		h = unknownFieldsMiddleware(recorder, rt, h)
+	}
+	// The request is decoded inside authentication, concurrency limits, the
+	// timeout, and panic recovery, so unauthenticated or excess requests are
+	// rejected before their body is read, and before middleware run so they
+	// can inspect the parent with composition.ParentFromContext.
+	if decodes {
+		h = decodeMiddleware(decoder, h)
+	}
+	if hs.recover {
+		h = recoverMiddleware(hs.logger, rt, h)
+	}
+	timeout := hs.timeout
+	if rt.config.timeout != nil {
+		timeout = *rt.config.timeout
+	}
+	if timeout > 0 {
+		msg := fmt.Sprintf("%s hook for %s did not complete within %s", rt.hookType, rt.resource(), timeout)
+		h = http.TimeoutHandler(h, timeout, msg)
+	}
+	if lim := newLimiter(rt.config.maxConcurrent); lim != nil {
+		h = hs.concurrencyMiddleware(lim, rt, h)
+	}
+	if hs.concurrency != nil {
+		h = hs.concurrencyMiddleware(hs.concurrency, rt, h)
+	}
+	if hs.verifyToken != nil {
+		h = authMiddleware(hs.verifyToken, hs.logger, h)
+	}
+	if rt.hookType == HookTypeSync {
+		h = hs.lastSyncMiddleware(rt, h)
+	}
+	if hs.metrics != nil {
+		h = metricsMiddleware(hs.metrics, rt, h)
+	}
+	if hs.tracer != nil {
+		h = tracingMiddleware(hs.tracer, rt, h)
+	}
+	if hs.accessLog {
+		logger := hs.accessLogger
+		if logger == nil {
+			logger = hs.logger
+		}
+		h = accessLogMiddleware(logger, rt, h)
+	}
+	h = peerCertMiddleware(h)
+	if hs.preprocess != nil {
+		h = preprocessMiddleware(hs.preprocess, h)
+	}
+	if hs.onRequest != nil {
+		h = onRequestMiddleware(hs.onRequest, rt, hs.logger, h)
+	}
+	if hs.compressionMinBytes > 0 {
+		h = compressionMiddleware(hs.compressionMinBytes, hs.maxRequestBytes, hs.logger, h)
+	}
+	if hs.hmacHeader != "" {
+		h = hmacMiddleware(hs.hmacSecret, hs.hmacHeader, hs.logger, h)
+	}
+	if len(hs.requiredHeaders) > 0 {
+		h = requireHeadersMiddleware(hs.requiredHeaders, hs.logger, h)
+	}
+	if hs.maxRequestBytes > 0 {
+		h = maxBytesMiddleware(hs.maxRequestBytes, h)
+	}
+	h = contentTypeMiddleware(hs.logger, hs.compressionMinBytes > 0, h)
+	if hs.jsonErrors {
+		h = jsonErrorsMiddleware(h)
+	}
+
+	return h
+}
+
+// recoverMiddleware recovers from panics raised by a hook handler, logging the
+// panic with its stack trace and responding 500 Internal Server Error.
+func recoverMiddleware(logger *slog.Logger, rt hookRoute, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logger.ErrorContext(r.Context(), "Recovered from panic in "+rt.hookType+" hook",
+				"path", rt.path,
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()))
+			writeError(r.Context(), w, http.StatusInternalServerError, fmt.Errorf("%s hook panicked: %v", rt.hookType, rec), logger)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentTypeMiddleware rejects Content-Encodings other than gzip, when
+// compression is enabled, with 415 Unsupported Media Type. Hook requests may be
+// JSON or, for hooks with typed objects, the protobuf envelope described in
+// protobuf.go; requests without a Content-Type are treated as JSON.
+func contentTypeMiddleware(logger *slog.Logger, compression bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentEncoding(r, compression); err != nil {
+			writeError(r.Context(), w, http.StatusUnsupportedMediaType, err, logger)
+
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBytesMiddleware limits the size of the request body read by a hook handler.
+func maxBytesMiddleware(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preprocessMiddleware replaces the request body with the reader returned by fn.
+func preprocessMiddleware(fn func(io.Reader) io.Reader, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{fn(r.Body), r.Body}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// onRequestMiddleware passes a copy of the request body to fn and restores the
+// body for decoding.
+func onRequestMiddleware(fn func(context.Context, string, []byte), rt hookRoute, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(r.Context(), w, http.StatusRequestEntityTooLarge, err, logger)
+
+				return
+			}
+			writeError(r.Context(), w, http.StatusBadRequest, fmt.Errorf("error reading request: %w", err), logger)
+
+			return
+		}
+		fn(r.Context(), rt.hookType, bytes.Clone(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder wraps an http.ResponseWriter to capture the response status code.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before delegating to the wrapped writer.
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 OK status before delegating to the wrapped writer.
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+
+	return rr.ResponseWriter.Write(b)
+}
+
+// Status returns the recorded status code, defaulting to 200 OK if nothing was written.
+func (rr *responseRecorder) Status() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+
+	return rr.status
+}
+
+// Unwrap returns the wrapped writer for use by http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
@@ -0,0 +1,74 @@
+package metacontroller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// countingReader counts the reads of the wrapped reader.
+type countingReader struct {
+	io.Reader
+	reads atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads.Add(1)
+
+	return r.Reader.Read(p)
+}
+
+func TestAuthRejectsBeforeReadingBody(t *testing.T) {
+	var synced atomic.Bool
+	hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		synced.Store(true)
+
+		return &composition.SyncResponse[*corev1.ConfigMap]{}, nil
+	}), StaticToken("secret"))
+
+	body := &countingReader{Reader: strings.NewReader(`{"parent":` + parentJSON + `}`)}
+	r := httptest.NewRequest(http.MethodPost, hs.HookPath(HookTypeSync, configMaps), body)
+	w := httptest.NewRecorder()
+	hs.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if n := body.reads.Load(); n != 0 {
+		t.Errorf("body read %d times before authentication", n)
+	}
+	if synced.Load() {
+		t.Error("syncer called for an unauthenticated request")
+	}
+}
+
+func TestMiddlewareSeesDecodedParent(t *testing.T) {
+	var name string
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if parent, ok := composition.ParentFromContext(r.Context()); ok {
+				name = parent.GetName()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return &composition.SyncResponse[*corev1.ConfigMap]{}, nil
+	}), StaticToken("secret"), Use(mw))
+
+	w := postSync(hs, `{"parent":`+parentJSON+`}`, http.Header{"Authorization": {"Bearer secret"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if name != "parent" {
+		t.Errorf("middleware saw parent %q, want %q", name, "parent")
+	}
+}
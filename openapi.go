@@ -0,0 +1,230 @@
+package metacontroller
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// OpenAPI registers an endpoint at the given path (e.g. "/openapi") that serves
+// the document returned by OpenAPISchema. The endpoint is not a hook route, so
+// hook middleware does not apply to it.
+func OpenAPI(path string) Option {
+	return func(hs *HookServer) {
+		hs.mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+			data, err := hs.OpenAPISchema()
+			if err != nil {
+				writeError(r.Context(), w, http.StatusInternalServerError, err, hs.logger)
+
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		})
+		hs.logger.Info("Registered OpenAPI schema", "path", path)
+	}
+}
+
+// OpenAPISchema returns an OpenAPI 3 document, as JSON, with a schema under
+// components.schemas for the Go type of each typed parent the registered hooks
+// decode, e.g. to compare a CRD's openAPIV3Schema with the types the hooks
+// actually use. Schemas are derived from the types' JSON tags: fields without
+// omitempty are required, metadata is an opaque object, well-known types such
+// as metav1.Time and resource.Quantity have their usual formats, and other
+// types with custom JSON encodings preserve unknown fields. Validation markers
+// and field descriptions are not available at run time and are omitted. Hooks
+// for generic parents, such as DispatchSyncHook, are skipped.
+func (hs *HookServer) OpenAPISchema() ([]byte, error) {
+	hs.hooksMu.RLock()
+	kinds := make(map[schema.GroupVersionKind]reflect.Type)
+	for _, ep := range hs.endpoints {
+		if ep.parentType != nil {
+			kinds[ep.parentKind] = ep.parentType
+		}
+	}
+	hs.hooksMu.RUnlock()
+
+	schemas := make(map[string]any, len(kinds))
+	for gvk, typ := range kinds {
+		s := typeSchema(typ, map[reflect.Type]bool{})
+		s["x-kubernetes-group-version-kind"] = []map[string]string{{
+			"group":   gvk.Group,
+			"version": gvk.Version,
+			"kind":    gvk.Kind,
+		}}
+		schemas[schemaName(gvk)] = s
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi":    "3.0.0",
+		"info":       map[string]string{"title": "Metacontroller parent resources", "version": "unversioned"},
+		"paths":      map[string]any{},
+		"components": map[string]any{"schemas": schemas},
+	}, "", "  ")
+}
+
+// schemaName returns the name of the schema for gvk, e.g.
+// "example.com.v1alpha1.Microservice", or "v1.ConfigMap" for the core group.
+func schemaName(gvk schema.GroupVersionKind) string {
+	if gvk.Group == "" {
+		return gvk.Version + "." + gvk.Kind
+	}
+
+	return gvk.Group + "." + gvk.Version + "." + gvk.Kind
+}
+
+// parentTyper is implemented by hook handlers to report their parent type.
+type parentTyper interface {
+	parentType() (schema.GroupVersionKind, reflect.Type, bool)
+}
+
+// parentTypeOf returns the kind and struct type of P. It reports false for
+// generic parent types (interfaces and *unstructured.Unstructured) and for
+// types not registered in scheme.
+func parentTypeOf[P client.Object](scheme *runtime.Scheme) (schema.GroupVersionKind, reflect.Type, bool) {
+	typ := reflect.TypeFor[P]()
+	if typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return schema.GroupVersionKind{}, nil, false
+	}
+	parent := reflect.New(typ.Elem()).Interface().(client.Object)
+	if _, ok := parent.(runtime.Unstructured); ok {
+		return schema.GroupVersionKind{}, nil, false
+	}
+	gvk, err := apiutil.GVKForObject(parent, scheme)
+	if err != nil {
+		return schema.GroupVersionKind{}, nil, false
+	}
+
+	return gvk, typ.Elem(), true
+}
+
+// Well-known types with custom JSON encodings.
+var (
+	timeTypes = []reflect.Type{
+		reflect.TypeFor[metav1.Time](),
+		reflect.TypeFor[metav1.MicroTime](),
+		reflect.TypeFor[time.Time](),
+	}
+	intOrStringTypes = []reflect.Type{
+		reflect.TypeFor[intstr.IntOrString](),
+		reflect.TypeFor[resource.Quantity](),
+	}
+	jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+)
+
+// typeSchema returns the OpenAPI schema of typ. visiting holds the struct
+// types being expanded, so recursive types preserve unknown fields instead of
+// recursing forever.
+func typeSchema(typ reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case slices.Contains(timeTypes, typ):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case slices.Contains(intOrStringTypes, typ):
+		return map[string]any{"x-kubernetes-int-or-string": true}
+	case typ == reflect.TypeFor[metav1.Duration]():
+		return map[string]any{"type": "string"}
+	case typ == reflect.TypeFor[metav1.ObjectMeta]():
+		return map[string]any{"type": "object"}
+	case typ == reflect.TypeFor[json.RawMessage](), typ == reflect.TypeFor[runtime.RawExtension]():
+		return map[string]any{"x-kubernetes-preserve-unknown-fields": true}
+	case typ.Implements(jsonMarshaler) || reflect.PointerTo(typ).Implements(jsonMarshaler):
+		return map[string]any{"x-kubernetes-preserve-unknown-fields": true}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+
+		return map[string]any{"type": "array", "items": typeSchema(typ.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(typ.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[typ] {
+			return map[string]any{"type": "object", "x-kubernetes-preserve-unknown-fields": true}
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+
+		properties := make(map[string]any)
+		var required []string
+		addStructFields(typ, visiting, properties, &required)
+		s := map[string]any{"type": "object"}
+		if len(properties) > 0 {
+			s["properties"] = properties
+		}
+		if len(required) > 0 {
+			slices.Sort(required)
+			s["required"] = required
+		}
+
+		return s
+	}
+
+	return map[string]any{"x-kubernetes-preserve-unknown-fields": true}
+}
+
+// addStructFields adds the schemas of the JSON fields of the struct type typ to
+// properties, flattening embedded structs without a JSON name as encoding/json
+// does, and appends the names of fields without omitempty to required.
+func addStructFields(typ reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addStructFields(embedded, visiting, properties, required)
+
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type, visiting)
+		if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package metacontroller
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// Pprof registers the net/http/pprof profiling handlers under prefix
+// (e.g. "/debug/pprof"). Profiles are served at "<prefix>/<name>" and an index
+// at "<prefix>/". The endpoints are not hook routes, so hook middleware, access
+// logging, and metrics do not apply to them. They expose process internals, so
+// only enable them on servers that are not reachable from untrusted networks.
+func Pprof(prefix string) Option {
+	return func(hs *HookServer) {
+		prefix = strings.TrimSuffix(prefix, "/")
+		hs.mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+			name := strings.TrimPrefix(r.URL.Path, prefix+"/")
+			if name == "" {
+				pprof.Index(w, r)
+
+				return
+			}
+			pprof.Handler(name).ServeHTTP(w, r)
+		})
+		hs.mux.HandleFunc(prefix+"/cmdline", pprof.Cmdline)
+		hs.mux.HandleFunc(prefix+"/profile", pprof.Profile)
+		hs.mux.HandleFunc(prefix+"/symbol", pprof.Symbol)
+		hs.mux.HandleFunc(prefix+"/trace", pprof.Trace)
+		hs.logger.Info("Registered pprof handlers", "path", prefix+"/")
+	}
+}
@@ -0,0 +1,262 @@
+package metacontroller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Hook requests and responses sent as application/vnd.kubernetes.protobuf use
+// an envelope equivalent to the following proto3 messages. Objects are encoded
+// with the Kubernetes protobuf serializer, so only kinds registered in the
+// scheme with generated protobuf marshalers (such as the built-in API types)
+// can be sent this way. The controller and child patches, which are not
+// Kubernetes objects, are embedded as JSON.
+//
+//	message HookRequest {
+//	  bytes controller = 1;               // JSON
+//	  bytes parent = 2;
+//	  map<string, Objects> children = 3;  // keyed as in the JSON request
+//	  map<string, Objects> related = 4;
+//	  bool finalizing = 5;
+//	}
+//
+//	message Objects {
+//	  map<string, bytes> objects = 1;     // keyed by namespace/name
+//	}
+//
+//	message HookResponse {
+//	  bytes status = 1;
+//	  repeated bytes children = 2;
+//	  bytes child_patches = 3;            // JSON
+//	  bool finalized = 4;
+//	  double resync_after_seconds = 5;
+//	}
+//
+// Customize hook requests use the same envelope; their responses are always
+// JSON.
+
+// protobufPrefix is the magic number that starts objects in the Kubernetes
+// protobuf encoding.
+var protobufPrefix = []byte{0x6b, 0x38, 0x73, 0x00}
+
+// errNoProtobuf is returned for objects that have no protobuf encoding: those
+// decoded or encoded as unstructured, and Go types without protobuf marshalers.
+var errNoProtobuf = errors.New("object has no protobuf encoding; use JSON")
+
+// isProtobuf reports whether data is an object in the Kubernetes protobuf
+// encoding.
+func isProtobuf(data []byte) bool {
+	return bytes.HasPrefix(data, protobufPrefix)
+}
+
+// isProtobufRequest reports whether the body of r is a protobuf envelope.
+func isProtobufRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	return err == nil && mediaType == runtime.ContentTypeProtobuf
+}
+
+// negotiateProtobuf reports whether the response to r should be protobuf, which
+// is the case when its Accept header lists protobuf before any media type that
+// accepts JSON, and whether JSON is acceptable as well. Quality values other
+// than zero, which excludes a media type, are ignored.
+func negotiateProtobuf(r *http.Request) (protobuf, jsonOK bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case runtime.ContentTypeProtobuf:
+			if !jsonOK {
+				protobuf = true
+			}
+		case runtime.ContentTypeJSON, "application/*", "*/*":
+			jsonOK = true
+		}
+	}
+
+	return protobuf, jsonOK || !protobuf
+}
+
+// unmarshalProtobufRequest decodes a HookRequest envelope into req.
+func unmarshalProtobufRequest(data []byte, req *rawCompositeRequest) error {
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			req.Controller = json.RawMessage(value)
+		case num == 2 && typ == protowire.BytesType:
+			req.Parent = json.RawMessage(value)
+		case (num == 3 || num == 4) && typ == protowire.BytesType:
+			objects := &req.Children
+			if num == 4 {
+				objects = &req.Related
+			}
+
+			return unmarshalObjectsEntry(value, objects)
+		case num == 5 && typ == protowire.VarintType:
+			req.Finalizing = varint != 0
+		}
+
+		return nil
+	})
+}
+
+// unmarshalObjectsEntry decodes a map<string, Objects> entry into objects.
+func unmarshalObjectsEntry(data []byte, objects *map[string]map[string]json.RawMessage) error {
+	var key string
+	byName := make(map[string]json.RawMessage)
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(value)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					return unmarshalObjectEntry(value, byName)
+				}
+
+				return nil
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if *objects == nil {
+		*objects = make(map[string]map[string]json.RawMessage)
+	}
+	if existing, ok := (*objects)[key]; ok {
+		for name, obj := range byName {
+			existing[name] = obj
+		}
+
+		return nil
+	}
+	(*objects)[key] = byName
+
+	return nil
+}
+
+// unmarshalObjectEntry decodes a map<string, bytes> entry into byName.
+func unmarshalObjectEntry(data []byte, byName map[string]json.RawMessage) error {
+	var name string
+	var obj []byte
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			name = string(value)
+		case num == 2 && typ == protowire.BytesType:
+			obj = value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	byName[name] = json.RawMessage(obj)
+
+	return nil
+}
+
+// consumeFields calls fn with each field of the protobuf message data, passing
+// the contents of length-delimited fields as value and the value of varint
+// fields as varint. Fields of other types are skipped.
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var value []byte
+		var varint uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if err := fn(num, typ, value, varint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeProtobuf writes the response into buf as a HookResponse envelope.
+func (resp compositeResponse) encodeProtobuf(buf *bytes.Buffer, encoder runtime.Encoder) error {
+	var b []byte
+	obj := getBuffer()
+	defer putBuffer(obj)
+	if !isNilObject(resp.status) {
+		if err := encoder.Encode(resp.status, obj); err != nil {
+			return fmt.Errorf("error encoding status: %w", err)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, obj.Bytes())
+	}
+	for _, child := range resp.children {
+		obj.Reset()
+		if err := encoder.Encode(child, obj); err != nil {
+			return fmt.Errorf("error encoding child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+		}
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, obj.Bytes())
+	}
+	if len(resp.childPatches) > 0 {
+		data, err := json.Marshal(resp.childPatches)
+		if err != nil {
+			return fmt.Errorf("error encoding child patches: %w", err)
+		}
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, data)
+	}
+	if resp.finalized {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if resp.resyncAfter > 0 {
+		b = protowire.AppendTag(b, 5, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(resp.resyncAfter.Seconds()))
+	}
+	buf.Write(b)
+
+	return nil
+}
+
+// readProtobufRequest reads a HookRequest envelope from body into req.
+func readProtobufRequest(body io.Reader, req *rawCompositeRequest) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalProtobufRequest(data, req)
+}
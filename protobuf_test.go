@@ -0,0 +1,277 @@
+package metacontroller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// protobufObject encodes obj, which must have its TypeMeta set, in the
+// Kubernetes protobuf encoding.
+func protobufObject(t testing.TB, obj runtime.Object) []byte {
+	t.Helper()
+	scheme := testScheme(t)
+	var buf bytes.Buffer
+	if err := protobuf.NewSerializer(scheme, scheme).Encode(obj, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// protobufRequest returns a HookRequest envelope with the given parent and
+// children, keyed by child key and then by namespace/name.
+func protobufRequest(parent []byte, children map[string]map[string][]byte) []byte {
+	b := protowire.AppendTag(nil, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, parent)
+	for key, byName := range children {
+		var objects []byte
+		for name, obj := range byName {
+			var entry []byte
+			entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+			entry = protowire.AppendString(entry, name)
+			entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, obj)
+			objects = protowire.AppendTag(objects, 1, protowire.BytesType)
+			objects = protowire.AppendBytes(objects, entry)
+		}
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, objects)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	return b
+}
+
+// protobufChildren returns the children of a HookResponse envelope.
+func protobufChildren(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var children [][]byte
+	err := consumeFields(data, func(num protowire.Number, _ protowire.Type, value []byte, _ uint64) error {
+		if num == 2 {
+			children = append(children, value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return children
+}
+
+// postProtobuf sends a protobuf body to the sync hook of hs with the given
+// Accept header and returns the response.
+func postProtobuf(hs *HookServer, body []byte, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, hs.HookPath(HookTypeSync, configMaps), bytes.NewReader(body))
+	r.Header.Set("Content-Type", runtime.ContentTypeProtobuf)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	hs.ServeHTTP(w, r)
+
+	return w
+}
+
+var (
+	protobufParent = &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "default"},
+	}
+	protobufSecret = &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "default"},
+		Data:       map[string][]byte{"k": []byte("v")},
+	}
+)
+
+// echoChildren is a Syncer that returns its observed children as desired.
+func echoChildren() composition.Syncer[*corev1.ConfigMap] {
+	return syncFunc(func(_ context.Context, req *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		var children []client.Object
+		for _, objs := range req.Children {
+			for _, obj := range objs {
+				child := obj.DeepCopyObject().(client.Object)
+				child.SetResourceVersion("")
+				children = append(children, child)
+			}
+		}
+
+		return &composition.SyncResponse[*corev1.ConfigMap]{Children: children}, nil
+	})
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	hs := newSyncServer(t, echoChildren())
+	body := protobufRequest(protobufObject(t, protobufParent), map[string]map[string][]byte{
+		"Secret.v1": {"default/s": protobufObject(t, protobufSecret)},
+	})
+
+	w := postProtobuf(hs, body, runtime.ContentTypeProtobuf)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != runtime.ContentTypeProtobuf {
+		t.Fatalf("Content-Type = %q, want %q", ct, runtime.ContentTypeProtobuf)
+	}
+	children := protobufChildren(t, w.Body.Bytes())
+	if len(children) != 1 {
+		t.Fatalf("got %d children, want 1", len(children))
+	}
+	scheme := testScheme(t)
+	obj, _, err := protobuf.NewSerializer(scheme, scheme).Decode(children[0], nil, nil)
+	if err != nil {
+		t.Fatalf("decoding child: %v", err)
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Name != "s" || string(secret.Data["k"]) != "v" {
+		t.Errorf("child = %#v, want Secret default/s with k=v", obj)
+	}
+}
+
+func TestProtobufNegotiation(t *testing.T) {
+	hs := newSyncServer(t, echoChildren())
+	body := protobufRequest(protobufObject(t, protobufParent), nil)
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: runtime.ContentTypeJSON},
+		{accept: runtime.ContentTypeJSON, want: runtime.ContentTypeJSON},
+		{accept: runtime.ContentTypeProtobuf, want: runtime.ContentTypeProtobuf},
+		{accept: runtime.ContentTypeProtobuf + ", application/json", want: runtime.ContentTypeProtobuf},
+		{accept: "application/json, " + runtime.ContentTypeProtobuf, want: runtime.ContentTypeJSON},
+		{accept: runtime.ContentTypeProtobuf + ";q=0, */*", want: runtime.ContentTypeJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := postProtobuf(hs, body, tt.accept)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.want {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtobufResponseFallsBackToJSON(t *testing.T) {
+	child := &unstructured.Unstructured{}
+	child.SetAPIVersion("example.com/v1")
+	child.SetKind("Widget")
+	child.SetName("w")
+	hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return &composition.SyncResponse[*corev1.ConfigMap]{Children: []client.Object{child}}, nil
+	}))
+	body := protobufRequest(protobufObject(t, protobufParent), nil)
+
+	tests := []struct {
+		accept   string
+		wantCode int
+		wantType string
+	}{
+		{accept: runtime.ContentTypeProtobuf + ", application/json", wantCode: http.StatusOK, wantType: runtime.ContentTypeJSON},
+		{accept: runtime.ContentTypeProtobuf, wantCode: http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := postProtobuf(hs, body, tt.accept)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", w.Header().Get("Content-Type"), tt.wantType)
+			}
+		})
+	}
+}
+
+func TestProtobufUnstructuredHook(t *testing.T) {
+	syncer := composition.SyncerFunc[*unstructured.Unstructured](func(context.Context, *runtime.Scheme, *composition.SyncRequest[*unstructured.Unstructured]) (*composition.SyncResponse[*unstructured.Unstructured], error) {
+		return &composition.SyncResponse[*unstructured.Unstructured]{}, nil
+	})
+	hs := NewHookServer(testScheme(t), discardLogger(), CompositeController(SyncHook(configMaps, syncer)))
+	body := protobufRequest(protobufObject(t, protobufParent), nil)
+
+	if w := postProtobuf(hs, body, ""); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusUnsupportedMediaType, w.Body)
+	}
+}
+
+// BenchmarkPayloadSize reports the size of a sync request and response with n
+// Secret children in JSON and in protobuf.
+func BenchmarkPayloadSize(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("children=%d", n), func(b *testing.B) {
+			hs := newSyncServer(b, echoChildren())
+			byName := make(map[string][]byte, n)
+			jsonChildren := &bytes.Buffer{}
+			for i := range n {
+				secret := protobufSecret.DeepCopy()
+				secret.Name = fmt.Sprintf("secret-%d", i)
+				secret.ResourceVersion = "12345"
+				secret.UID = "6b1e0c4e-3f0d-4a57-9d0b-5b2f0c0f7e21"
+				byName["default/"+secret.Name] = protobufObject(b, secret)
+				if i > 0 {
+					jsonChildren.WriteByte(',')
+				}
+				data, err := runtime.Encode(unstructured.UnstructuredJSONScheme, secretUnstructured(b, secret))
+				if err != nil {
+					b.Fatal(err)
+				}
+				fmt.Fprintf(jsonChildren, "%q:%s", "default/"+secret.Name, data)
+			}
+			protoBody := protobufRequest(protobufObject(b, protobufParent), map[string]map[string][]byte{"Secret.v1": byName})
+			jsonBody := `{"parent":` + parentJSON + `,"children":{"Secret.v1":{` + jsonChildren.String() + `}}}`
+
+			var jsonSize, protoSize int
+			b.ResetTimer()
+			for range b.N {
+				w := postSync(hs, jsonBody, nil)
+				if w.Code != http.StatusOK {
+					b.Fatalf("JSON status = %d: %s", w.Code, w.Body)
+				}
+				jsonSize = len(jsonBody) + w.Body.Len()
+				w = postProtobuf(hs, protoBody, runtime.ContentTypeProtobuf)
+				if w.Code != http.StatusOK {
+					b.Fatalf("protobuf status = %d: %s", w.Code, w.Body)
+				}
+				protoSize = len(protoBody) + w.Body.Len()
+			}
+			b.ReportMetric(float64(jsonSize), "json-bytes")
+			b.ReportMetric(float64(protoSize), "protobuf-bytes")
+		})
+	}
+}
+
+// secretUnstructured converts secret to unstructured for JSON encoding.
+func secretUnstructured(b *testing.B, secret *corev1.Secret) *unstructured.Unstructured {
+	b.Helper()
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(secret)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return &unstructured.Unstructured{Object: u}
+}
@@ -0,0 +1,63 @@
+package metacontroller
+
+import (
+	"context"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// hookRequest is a hook request decoded ahead of the hook's middleware. If
+// decoding failed, err is reported by the hook handler with status code, so
+// the failure is still observed by metrics, tracing, and access logs.
+type hookRequest struct {
+	raw    rawCompositeRequest
+	parent client.Object
+	code   int
+	err    error
+}
+
+// hookRequestKey is the context key for the decoded hookRequest.
+type hookRequestKey struct{}
+
+// requestDecoder is implemented by hook handlers that decode their request
+// ahead of the hook's middleware.
+type requestDecoder interface {
+	decodeRequest(r *http.Request) *hookRequest
+}
+
+// decodeMiddleware decodes the hook request with d and stores it in the request
+// context, making the parent available through composition.ParentFromContext.
+func decodeMiddleware(d requestDecoder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := d.decodeRequest(r)
+		ctx := context.WithValue(r.Context(), hookRequestKey{}, req)
+		if req.err == nil {
+			ctx = composition.WithParent(ctx, req.parent)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestFrom returns the hook request decoded by decodeMiddleware, or decodes
+// it with d if the handler is served without the middleware.
+func requestFrom(r *http.Request, d requestDecoder) *hookRequest {
+	if req, ok := r.Context().Value(hookRequestKey{}).(*hookRequest); ok {
+		return req
+	}
+
+	return d.decodeRequest(r)
+}
+
+// peerCertMiddleware stores the verified client certificate of requests made
+// over TLS in the request context for composition.PeerCertFromContext.
+func peerCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			r = r.WithContext(composition.WithPeerCert(r.Context(), r.TLS.VerifiedChains[0][0]))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
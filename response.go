@@ -0,0 +1,174 @@
+package metacontroller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+// maxPooledBufferSize is the capacity above which response buffers are not
+// returned to the pool, so one unusually large response does not pin memory.
+const maxPooledBufferSize = 4 << 20
+
+// bufferPool holds buffers used to encode hook responses.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// putBuffer returns buf to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// compositeResponse is a sync or finalize hook response awaiting encoding. It
+// is encoded as {"status": ..., "children": [...], "childPatches": [...],
+// "finalized": ...}. A nil status is omitted, which leaves the parent's status
+// untouched.
+type compositeResponse struct {
+	status       runtime.Object
+	children     []client.Object
+	childPatches []composition.ChildPatch
+	finalized    bool
+	resyncAfter  time.Duration
+}
+
+// encode writes the response as JSON into buf. The status and each child are
+// encoded directly into buf rather than into intermediate byte slices.
+func (resp compositeResponse) encode(buf *bytes.Buffer, encoder runtime.Encoder) error {
+	buf.WriteByte('{')
+	sep := ""
+	if !isNilObject(resp.status) {
+		buf.WriteString(`"status":`)
+		if err := encodeInto(buf, encoder, resp.status); err != nil {
+			return fmt.Errorf("error encoding status: %w", err)
+		}
+		sep = ","
+	}
+
+	if len(resp.children) > 0 {
+		buf.WriteString(sep + `"children":[`)
+		for i, child := range resp.children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeInto(buf, encoder, child); err != nil {
+				return fmt.Errorf("error encoding child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+			}
+		}
+		buf.WriteByte(']')
+		sep = ","
+	}
+	if len(resp.childPatches) > 0 {
+		data, err := json.Marshal(resp.childPatches)
+		if err != nil {
+			return fmt.Errorf("error encoding child patches: %w", err)
+		}
+		buf.WriteString(sep + `"childPatches":`)
+		buf.Write(data)
+		sep = ","
+	}
+
+	if resp.finalized {
+		buf.WriteString(sep + `"finalized":true`)
+		sep = ","
+	}
+	if resp.resyncAfter > 0 {
+		buf.WriteString(sep + `"resyncAfterSeconds":` + strconv.FormatFloat(resp.resyncAfter.Seconds(), 'f', -1, 64))
+	}
+	buf.WriteString("}\n")
+
+	return nil
+}
+
+// unchangedChildren returns copies of the observed children, ordered by kind,
+// namespace, and name, to return as desired children so that they are left
+// unchanged. Metadata populated by the API server (resourceVersion, uid,
+// generation, creation and deletion timestamps, and managed fields) and status
+// are removed, since Metacontroller would otherwise record them as fields the
+// hook manages, and a stale resourceVersion can make its updates conflict.
+func unchangedChildren(observed map[schema.GroupVersionKind][]client.Object) ([]client.Object, error) {
+	children := flattenChildren(observed)
+	for i, child := range children {
+		u, err := withoutServerFields(child)
+		if err != nil {
+			return nil, fmt.Errorf("child %s/%s: %w", child.GetNamespace(), child.GetName(), err)
+		}
+		children[i] = u
+	}
+
+	return children, nil
+}
+
+// serverFields are the paths of the fields populated by the API server.
+var serverFields = [][]string{
+	{"status"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "managedFields"},
+	{"metadata", "selfLink"},
+}
+
+// withoutServerFields returns an unstructured copy of obj without the fields
+// populated by the API server.
+func withoutServerFields(obj client.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	for _, path := range serverFields {
+		unstructured.RemoveNestedField(u.Object, path...)
+	}
+
+	return u, nil
+}
+
+// isNilObject reports whether obj is nil or a typed nil pointer, as is the zero
+// value of a pointer type parameter.
+func isNilObject(obj runtime.Object) bool {
+	if obj == nil {
+		return true
+	}
+	v := reflect.ValueOf(obj)
+
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// encodeInto encodes obj into buf, dropping the trailing newline that
+// serializers append so the object can be embedded in a larger document.
+func encodeInto(buf *bytes.Buffer, encoder runtime.Encoder, obj runtime.Object) error {
+	if err := encoder.Encode(obj, buf); err != nil {
+		return err
+	}
+	if n := buf.Len(); n > 0 && buf.Bytes()[n-1] == '\n' {
+		buf.Truncate(n - 1)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package metacontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/a2y-d5l/go-metacontroller/composition"
+)
+
+func TestDeferredSyncStripsServerFields(t *testing.T) {
+	hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return nil, composition.Defer(time.Minute)
+	}))
+
+	body := `{"parent":` + parentJSON + `,"children":{"Pod.v1":{"default/p":{
+		"apiVersion":"v1","kind":"Pod",
+		"metadata":{"name":"p","namespace":"default","labels":{"app":"a"},"resourceVersion":"42","uid":"u1","generation":3,
+			"creationTimestamp":"2024-01-01T00:00:00Z","managedFields":[{"manager":"kubectl"}]},
+		"spec":{"containers":[{"name":"c","image":"i"}]},
+		"status":{"phase":"Running"}}}}}`
+	w := postSync(hs, body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var resp struct {
+		Children           []map[string]any `json:"children"`
+		ResyncAfterSeconds float64          `json:"resyncAfterSeconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ResyncAfterSeconds != 60 {
+		t.Errorf("resyncAfterSeconds = %v, want 60", resp.ResyncAfterSeconds)
+	}
+	if len(resp.Children) != 1 {
+		t.Fatalf("got %d children, want 1", len(resp.Children))
+	}
+	child := resp.Children[0]
+	if _, ok := child["status"]; ok {
+		t.Errorf("child has status: %v", child["status"])
+	}
+	if _, ok := child["spec"]; !ok {
+		t.Error("child has no spec")
+	}
+	metadata := child["metadata"].(map[string]any)
+	for _, field := range []string{"resourceVersion", "uid", "generation", "creationTimestamp", "managedFields"} {
+		if v, ok := metadata[field]; ok {
+			t.Errorf("child metadata.%s = %v, want it cleared", field, v)
+		}
+	}
+	if metadata["name"] != "p" || metadata["labels"] == nil {
+		t.Errorf("child metadata = %v, want name and labels kept", metadata)
+	}
+}
+
+func TestDeferredSyncFailsWithUndecodableChildren(t *testing.T) {
+	hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+		return nil, composition.Defer(time.Minute)
+	}))
+
+	body := `{"parent":` + parentJSON + `,"children":{"Widget.example.com/v1":{"default/w":{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}}}}`
+	if w := postSync(hs, body, nil); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+// BenchmarkEncodeResponse compares encoding a sync response with 50 children
+// into a pooled buffer with encoding each child into its own byte slice and
+// marshaling the response map, as hooks did before.
+func BenchmarkEncodeResponse(b *testing.B) {
+	hs := NewHookServer(testScheme(b), discardLogger())
+	encoder := hs.encoder(hookConfig{})
+	resp := compositeResponse{status: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "default"}}}
+	for i := range 50 {
+		resp.children = append(resp.children, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("secret-%d", i), Namespace: "default", Labels: map[string]string{"app": "bench"}},
+			Data:       map[string][]byte{"k": []byte("v")},
+		})
+	}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			buf := getBuffer()
+			if err := resp.encode(buf, encoder); err != nil {
+				b.Fatal(err)
+			}
+			putBuffer(buf)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			status, err := runtime.Encode(encoder, resp.status)
+			if err != nil {
+				b.Fatal(err)
+			}
+			children := make([]json.RawMessage, 0, len(resp.children))
+			for _, child := range resp.children {
+				data, err := runtime.Encode(encoder, child)
+				if err != nil {
+					b.Fatal(err)
+				}
+				children = append(children, data)
+			}
+			if _, err := json.Marshal(map[string]any{"status": json.RawMessage(status), "children": children}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestSyncResponseStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     *corev1.ConfigMap
+		wantStatus bool
+	}{
+		{name: "nil status omitted", status: nil},
+		{name: "empty status sent", status: &corev1.ConfigMap{}, wantStatus: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := newSyncServer(t, syncFunc(func(context.Context, *composition.SyncRequest[*corev1.ConfigMap]) (*composition.SyncResponse[*corev1.ConfigMap], error) {
+				return &composition.SyncResponse[*corev1.ConfigMap]{Status: tt.status}, nil
+			}))
+			w := postSync(hs, `{"parent":`+parentJSON+`}`, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+
+			var resp map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			status, ok := resp["status"]
+			if ok != tt.wantStatus {
+				t.Fatalf("response %s has status: %v, want %v", w.Body, ok, tt.wantStatus)
+			}
+			if ok && string(status) == "null" {
+				t.Errorf("status = null, want an object")
+			}
+		})
+	}
+}
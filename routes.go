@@ -0,0 +1,128 @@
+package metacontroller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RouteInfo describes a hook endpoint registered on a HookServer.
+type RouteInfo struct {
+	// HookType is the type of hook served: HookTypeSync, HookTypeFinalize, or
+	// HookTypeCustomize.
+	HookType string
+	// GVR identifies the parent resource the hook serves. It is empty for hooks
+	// that serve several resources, such as DispatchSyncHook.
+	GVR schema.GroupVersionResource
+	// Path is the URL path the hook is served at, suitable for a
+	// CompositeController's webhook path.
+	Path string
+}
+
+// hookEndpoint is a hook registered at a path.
+type hookEndpoint struct {
+	route      RouteInfo
+	handler    http.Handler
+	notAllowed http.Handler
+	err        error
+	parentKind schema.GroupVersionKind
+	parentType reflect.Type
+}
+
+// Routes returns the hook endpoints registered on the HookServer, in
+// registration order.
+func (hs *HookServer) Routes() []RouteInfo {
+	hs.hooksMu.RLock()
+	defer hs.hooksMu.RUnlock()
+
+	return slices.Clone(hs.routes)
+}
+
+// Validate checks the registered hooks and returns an error describing each
+// hook whose parent type is not registered in the scheme or does not belong to
+// the hook's resource. Such hooks would reject every request, so
+// ListenAndServe, ListenAndServeTLS, and Run call Validate and fail to start;
+// call it directly when mounting the HookServer with Handler.
+func (hs *HookServer) Validate() error {
+	hs.hooksMu.RLock()
+	defer hs.hooksMu.RUnlock()
+
+	var errs []error
+	for _, route := range hs.routes {
+		if err := hs.endpoints[route.Path].err; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Register registers hooks (created with SyncHook, FinalizeHook, CustomizeHook,
+// or DispatchSyncHook) on a HookServer that may already be serving. A hook
+// registered at the path of an existing hook replaces it. Hooks use the server's
+// options as set by NewHookServer.
+func (hs *HookServer) Register(hooks ...CompositeHook) {
+	for _, hook := range hooks {
+		hook(hs)
+	}
+}
+
+// Unregister removes the hook of the given type for the parent resource
+// identified by gvr, which may be serving. Requests in flight complete; later
+// requests to its path are answered with 404 Not Found. It reports whether a
+// hook was registered.
+func (hs *HookServer) Unregister(hookType string, gvr schema.GroupVersionResource) bool {
+	path := hs.HookPath(hookType, gvr)
+
+	hs.hooksMu.Lock()
+	defer hs.hooksMu.Unlock()
+	if _, ok := hs.endpoints[path]; !ok {
+		return false
+	}
+	delete(hs.endpoints, path)
+	hs.routes = slices.DeleteFunc(hs.routes, func(route RouteInfo) bool {
+		return route.Path == path
+	})
+	hs.logger.Info("Unregistered "+hookType+" hook", "path", path, "gvr", gvr.String())
+
+	return true
+}
+
+// handleHook serves a hook handler for the parent resource identified by gvr,
+// wrapped with the server's hook middleware. Hooks are kept in a table guarded
+// by a mutex rather than on the ServeMux, which cannot remove handlers.
+func (hs *HookServer) handleHook(hookType string, gvr schema.GroupVersionResource, cfg hookConfig, h http.Handler) {
+	rt := hookRoute{hookType: hookType, gvr: gvr, path: hs.HookPath(hookType, gvr), config: cfg}
+	ep := hookEndpoint{
+		route:      RouteInfo{HookType: hookType, GVR: gvr, Path: rt.path},
+		handler:    hs.wrapHook(rt, h),
+		notAllowed: hs.methodNotAllowed(http.MethodPost),
+	}
+	if t, ok := h.(parentTyper); ok {
+		if gvk, typ, ok := t.parentType(); ok {
+			ep.parentKind, ep.parentType = gvk, typ
+		}
+	}
+	if c, ok := h.(parentTypeChecker); ok {
+		if err := c.checkParentType(); err != nil {
+			ep.err = fmt.Errorf("%s hook at %s: %w", hookType, rt.path, err)
+			hs.logger.Error("Invalid "+hookType+" hook", "path", rt.path, "gvr", gvr.String(), "error", err)
+		}
+	}
+
+	hs.hooksMu.Lock()
+	defer hs.hooksMu.Unlock()
+	if _, ok := hs.endpoints[rt.path]; ok {
+		i := slices.IndexFunc(hs.routes, func(route RouteInfo) bool { return route.Path == rt.path })
+		hs.routes[i] = ep.route
+		hs.logger.Info("Replaced "+rt.hookType+" hook", "path", rt.path, "gvr", rt.gvr.String())
+	} else {
+		hs.routes = append(hs.routes, ep.route)
+		hs.logger.Info("Registered "+rt.hookType+" hook", "path", rt.path, "gvr", rt.gvr.String())
+	}
+	hs.endpoints[rt.path] = ep
+}
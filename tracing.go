@@ -0,0 +1,91 @@
+package metacontroller
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts a span around each hook request. It keeps the HookServer
+// independent of any tracing library; an OpenTelemetry implementation extracts
+// the incoming W3C trace context from the request headers and starts a server
+// span from a trace.TracerProvider:
+//
+//	type otelTracer struct {
+//		tracer     trace.Tracer
+//		propagator propagation.TextMapPropagator
+//	}
+//
+//	func (t otelTracer) Start(r *http.Request, name string) (context.Context, metacontroller.Span) {
+//		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	metacontroller.Tracing(otelTracer{tp.Tracer("metacontroller"), propagation.TraceContext{}})
+type Tracer interface {
+	// Start starts a span named after the hook type and parent resource, e.g.
+	// "sync microservices.example.com/v1alpha1". The returned context carries
+	// the span and is passed to the Syncer, Finalizer, or Customizer.
+	Start(r *http.Request, spanName string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// RecordError records an error that caused the hook to fail.
+	RecordError(err error)
+	// End ends the span with the HTTP status code of the hook response.
+	End(statusCode int)
+}
+
+// TraceIDSpan is optionally implemented by a Span to expose the ID of its
+// trace, which is passed to a MetricsRecorder that implements ExemplarRecorder.
+// An OpenTelemetry implementation returns span.SpanContext().TraceID().String().
+type TraceIDSpan interface {
+	// TraceID returns the hex-encoded trace ID, or "" if the span is not
+	// sampled or has no valid trace.
+	TraceID() string
+}
+
+// Tracing creates an option that starts a span around every hook request.
+func Tracing(tracer Tracer) Option {
+	return func(hs *HookServer) {
+		hs.tracer = tracer
+	}
+}
+
+// spanKey is the context key for the active hook span.
+type spanKey struct{}
+
+// spanFromContext returns the active hook span, if any.
+func spanFromContext(ctx context.Context) (Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+
+	return span, ok
+}
+
+// traceIDFromContext returns the trace ID of the active hook span, or "" if
+// there is none or it does not implement TraceIDSpan.
+func traceIDFromContext(ctx context.Context) string {
+	span, ok := spanFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tspan, ok := span.(TraceIDSpan)
+	if !ok {
+		return ""
+	}
+
+	return tspan.TraceID()
+}
+
+// tracingMiddleware starts a span around each request to a hook route.
+func tracingMiddleware(tracer Tracer, rt hookRoute, next http.Handler) http.Handler {
+	name := rt.hookType + " " + rt.resource()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r, name)
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() { span.End(rec.Status()) }()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(ctx, spanKey{}, span)))
+	})
+}